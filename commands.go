@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"orderfile/organizer"
+)
+
+// runApply implements "gorder apply <plan.json>"
+func runApply(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	dryRun := fs.Bool("dry", false, "Show the plan without moving files")
+	fs.BoolVar(dryRun, "d", false, "Show the plan without moving files (shorthand)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE:\n    gorder apply [-d] <plan.json>\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	plan, err := organizer.LoadPlan(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *dryRun {
+		printPlan(plan)
+		return
+	}
+
+	res, err := organizer.Apply(context.Background(), plan)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printResult(res)
+	fmt.Printf("\nApply complete: %d/%d files moved.\n", len(res.Moved), len(plan.Moves))
+}
+
+// runRules implements "gorder rules test [options] <file>...". It takes
+// the organizing options of the main command, so the destinations it
+// explains are the ones a run with the same options would use.
+func runRules(args []string) {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprintf(os.Stderr, "USAGE:\n    gorder rules test [options] <file>...\n")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("rules test", flag.ExitOnError)
+	of := addOrganizeFlags(fs)
+	fs.Parse(args[1:])
+
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "USAGE:\n    gorder rules test [options] <file>...\n")
+		os.Exit(2)
+	}
+
+	org, err := organizer.New(of.options())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i, path := range fs.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		ex, err := org.Explain(path)
+		if err != nil {
+			log.Printf("Error explaining %s: %v\n", path, err)
+			continue
+		}
+
+		fmt.Printf("%s\n", ex.Path)
+		if ex.Filtered != "" {
+			fmt.Printf("  → %s: file is left alone\n", ex.Filtered)
+			continue
+		}
+		if len(ex.Checks) == 0 {
+			fmt.Println("  no rules configured")
+		}
+		for _, checks := range ex.Checks {
+			fmt.Printf("  %s\n", checks[0])
+			for _, check := range checks[1:] {
+				fmt.Printf("    %s\n", check)
+			}
+		}
+
+		switch {
+		case ex.Rule != nil && ex.Move == nil:
+			fmt.Printf("  → matched, %s: file is left alone\n", ex.Rule.Action)
+		case ex.Rule != nil:
+			fmt.Printf("  → matched, would %s to %s\n", ex.Rule.Action, ex.Move.Dest)
+		case ex.Move != nil:
+			fmt.Printf("  → no rule matched, default grouping would move it to %s\n", ex.Move.Dest)
+		default:
+			fmt.Println("  → no rule matched, file is left alone")
+		}
+	}
+}
+
+// runHistory implements "gorder history"
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE:\n    gorder history\n")
+	}
+	fs.Parse(args)
+
+	ops, err := organizer.History(".")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(ops) == 0 {
+		fmt.Println("No operations recorded.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tKIND\tFILES\tSTATUS\tCOMMAND")
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := ops[i]
+		status := "done"
+		switch {
+		case op.Undone() && op.Kind == organizer.KindUndo:
+			status = "redone by " + op.RevertedBy
+		case op.Undone():
+			status = "undone by " + op.RevertedBy
+		case op.RevertedBy != "":
+			status = "partly undone by " + op.RevertedBy
+		case op.Reverts != "":
+			status = "reverts " + op.Reverts
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", op.ID, op.Time.Local().Format("2006-01-02 15:04:05"),
+			op.Kind, len(op.Moves), status, strings.Join(op.Command, " "))
+	}
+	w.Flush()
+}
+
+// runUndo implements "gorder undo [--last N] [--force] [<id>]"
+func runUndo(args []string) {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	last := fs.Int("last", 1, "Undo the N latest operations, latest first")
+	force := fs.Bool("force", false, "Undo even if later operations touched the same files or files changed since")
+	onConflict := fs.String("on-conflict", organizer.ConflictSkip, "When the original location is taken: skip, rename, overwrite or prompt")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE:\n    gorder undo [--last N] [--force] [--on-conflict <policy>] [<id>]\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 || *last < 1 || fs.NArg() == 1 && *last != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	opts := organizer.UndoOptions{
+		ID:         fs.Arg(0),
+		Force:      *force,
+		OnConflict: *onConflict,
+		Prompt:     promptConflict,
+	}
+	for i := 0; i < *last; i++ {
+		if i > 0 {
+			fmt.Println()
+		}
+		res, err := performUndo(ctx, opts)
+		if i > 0 && errors.Is(err, organizer.ErrNoLog) {
+			fmt.Println("No more operations to undo.")
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Stop rather than undo older operations on top of a partial undo
+		if len(res.Failed) > 0 && i < *last-1 {
+			log.Fatalf("Stopping after a partial undo; %d operations left as they are.", *last-i-1)
+		}
+	}
+}
+
+// runRedo implements "gorder redo [--force] [<id>]"
+func runRedo(args []string) {
+	fs := flag.NewFlagSet("redo", flag.ExitOnError)
+	force := fs.Bool("force", false, "Redo even if later operations touched the same files")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE:\n    gorder redo [--force] [<id>]\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	res, err := organizer.Redo(context.Background(), ".", organizer.UndoOptions{ID: fs.Arg(0), Force: *force})
+	if err != nil {
+		log.Fatal(err)
+	}
+	printResult(res)
+	fmt.Printf("\nRedo complete: %d/%d files moved.\n", len(res.Moved), len(res.Moved)+len(res.Failed))
+}
+
+// runCache implements "gorder cache stats|prune"
+func runCache(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "USAGE:\n    gorder cache stats\n    gorder cache prune\n")
+		os.Exit(2)
+	}
+	if len(args) != 1 {
+		usage()
+	}
+
+	cache, err := organizer.OpenHashCache("")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch args[0] {
+	case "stats":
+		stats := cache.Stats()
+		fmt.Printf("Cache: %s\n", stats.Path)
+		fmt.Printf("Size: %s\n", organizer.FormatSize(stats.Size))
+		fmt.Printf("Files: %d\n", stats.Files)
+		fmt.Printf("Hashes: %d\n", stats.Entries)
+		algorithms := make([]string, 0, len(stats.Algorithms))
+		for name := range stats.Algorithms {
+			algorithms = append(algorithms, name)
+		}
+		sort.Strings(algorithms)
+		for _, name := range algorithms {
+			fmt.Printf("  %-8s %d\n", name, stats.Algorithms[name])
+		}
+	case "prune":
+		pruned, err := cache.Prune()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Pruned %d cached hashes, %d left.\n", pruned, cache.Stats().Entries)
+	default:
+		usage()
+	}
+}
+
+// promptConflict asks what to do with a file whose original location is
+// taken
+func promptConflict(move organizer.Move) string {
+	for {
+		fmt.Printf("%s already exists. Restore %s: [s]kip, [r]ename, [o]verwrite? ", move.Dest, move.Source)
+		var response string
+		fmt.Scanln(&response)
+		switch strings.ToLower(response) {
+		case "s", "skip", "":
+			return organizer.ConflictSkip
+		case "r", "rename":
+			return organizer.ConflictRename
+		case "o", "overwrite":
+			return organizer.ConflictOverwrite
+		}
+	}
+}
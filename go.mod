@@ -1,3 +1,11 @@
 module orderfile
 
 go 1.25.5
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/cespare/xxhash/v2 v2.3.0
+	golang.org/x/crypto v0.55.0
+	golang.org/x/sys v0.47.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -1,50 +1,19 @@
 package main
 
 import (
-	"bufio"
-	"crypto/md5"
-	"encoding/hex"
-	"errors"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"sort"
+	"os/signal"
 	"strings"
 	"time"
-)
-
-// categoryMap defines grouping of extensions into categories
-var categoryMap = map[string][]string{
-	"Images":        {"jpg", "jpeg", "png", "gif", "webp", "bmp", "tiff", "tif", "svg", "heic", "heif", "ico", "raw", "cr2", "nef", "orf", "arw", "psb", "dds", "hdr", "jp2"},
-	"Videos":        {"mp4", "mov", "avi", "mkv", "wmv", "flv", "mpeg", "mpg", "m4v", "3gp", "webm", "vob", "ts", "m2ts", "rm", "rmvb", "asf"},
-	"Audio":         {"mp3", "wav", "aac", "flac", "ogg", "m4a", "wma", "alac", "aiff", "amr", "mid", "midi", "opus", "pcm"},
-	"Documents":     {"doc", "docx", "pdf", "txt", "rtf", "odt", "md", "epub", "tex", "ps", "pages", "djvu", "fodt", "rtfd"},
-	"Spreadsheets":  {"xls", "xlsx", "csv", "ods", "tsv", "xlsm", "xlsb", "numbers"},
-	"Presentations": {"ppt", "pptx", "odp", "key", "pps", "ppsx"},
-	"Archives":      {"zip", "tar", "tar.gz", "tgz", "rar", "7z", "xz", "iso", "bz2", "gz", "lz", "lzma", "cab", "zst", "arj"},
-	"Executables":   {"exe", "msi", "bat", "cmd", "apk", "aab", "ipa", "dmg", "pkg", "app", "deb", "rpm", "flatpak", "snap", "jar", "war", "bin", "sh"},
-	"Web":           {"html", "htm", "css", "js", "ts", "jsx", "tsx"},
-	"Data":          {"json", "xml", "yaml", "yml", "ini", "toml", "ndjson"},
-	"Code":          {"c", "h", "cpp", "hpp", "cs", "java", "kt", "py", "rb", "php", "go", "rs", "swift", "scala", "lua", "pl", "ps1", "sql", "r", "m", "asm", "dart"},
-	"Design":        {"psd", "psb", "ai", "eps", "indd", "xd", "fig", "sketch", "cdr", "afdesign", "afphoto", "afpub"},
-	"Fonts":         {"ttf", "otf", "woff", "woff2", "eot", "fon", "pfb", "pfa"},
-	"3D":            {"blend", "fbx", "obj", "stl", "3ds", "dae", "ply", "glb", "gltf", "max", "usd", "usdz"},
-	"CAD":           {"dwg", "dxf", "dwt", "stp", "step", "iges", "igs", "sldprt", "sldasm", "ipt", "iam"},
-	"Config":        {"log", "cfg", "conf", "env", "editorconfig", "properties", "jsonc", "reg"},
-	"Database":      {"db", "sqlite", "sqlite3", "mdb", "accdb", "dbf", "parquet", "feather", "hdf5", "h5"},
-	"Backup":        {"bak", "tmp", "old", "backup", "swp", "swo"},
-}
-
-type moveAction struct {
-	from string
-	to   string
-}
+	// Embed the zone database so --tz works where the system has none
+	_ "time/tzdata"
 
-var logFile *os.File
-var moveLog []moveAction
+	"orderfile/organizer"
+)
 
 func main() {
 	// Custom usage/help message
@@ -53,6 +22,12 @@ func main() {
 
 USAGE:
     gorder [options]
+    gorder apply [-d] <plan.json>
+    gorder rules test [options] <file>...
+    gorder history
+    gorder undo [--last N] [--force] [--on-conflict <policy>] [<id>]
+    gorder redo [--force] [<id>]
+    gorder cache stats|prune
 
 DESCRIPTION:
     Intelligently organizes files using multiple strategies: extension-based,
@@ -61,38 +36,103 @@ DESCRIPTION:
 
 ORGANIZATION MODES:
     -c, -categories              Group files by categories (Images, Documents, etc.)
-    --date-mode <mode>           Group by date: 'year', 'month', 'day', or 'week'
+    --date-mode <mode>           Group by date: 'year', 'quarter', 'month', 'week'
+                                 (ISO week, e.g. 2024-W05), 'day', 'fiscal-year' or
+                                 'decade'; nest with slashes, e.g. 'year/month'
+    --date-format <format>       Name date folders with a Go layout ('2006/01') or
+                                 strftime format ('%%Y/%%m', '%%G-W%%V', '%%Y-Q%%q')
+    --fiscal-year-start <month>  First month of the fiscal year (e.g. 'apr');
+                                 FY2025 is the fiscal year ending in 2025
+    --tz <zone>                  Group dates in this time zone, e.g. 'UTC' or
+                                 'Europe/Berlin' (default: local time)
+    --date-source <source>       Where dates come from: 'auto' (default: EXIF, then
+                                 video/audio metadata, then file name, then
+                                 modification time), 'exif', 'media', 'mtime',
+                                 'ctime', 'birth' or 'filename'
+    --group-by <list>            Nest strategies in order: 'extension', 'category',
+                                 'date' (e.g. 'category/date'); -c with --date-mode
+                                 is the same as 'category/date'
+    --layout <template>          Destination template, e.g.
+                                 '{category}/{year}/{month}/{name}'. Placeholders:
+                                 category, ext, year, month, day, week, size,
+                                 parent, name, stem, counter ({counter:3}),
+                                 quarter, weekyear (ISO year of week);
+                                 date parts take a source, e.g. {year:filename}
 
 FILE HANDLING:
     -d, -dry, -dryrun           Preview changes without moving files
+    --save-plan <file>          Write the planned moves as JSON instead of moving
+    --on-conflict <policy>      When a destination exists: 'rename' (default),
+                                'skip', 'overwrite', 'keep-newer', 'keep-larger'
+                                or 'dedupe'; replaced files go to the trash
+    --mode <mode>               How files are placed: 'move' (default), 'copy',
+                                'hardlink' or 'symlink'; originals are kept
+                                unless moving
     -f, -full                   Use full extensions (e.g., tar.gz instead of gz)
     -q, -quiet                  Use simple folder names without gorder_ prefix
     --noext-folder <name>       Folder name for files without extensions
     --case-sensitive            Treat extensions as case-sensitive
 
 FILTERING:
-    -i, -include <list>         Comma-separated list of extensions to include
-    -e, -exclude <list>         Comma-separated list of extensions to exclude
+    -i, -include <list>         Comma-separated extensions, names or globs to include
+    -e, -exclude <list>         Comma-separated extensions, names or globs to exclude
+                                (globs: *.tmp, IMG_*, docs/**/*.pdf; regex: re:<expr>)
+    --exclude-dir <list>        Comma-separated globs of directories to skip entirely
+                                (.git, .hg, .svn and .gorderignore entries are always skipped)
 
 ADVANCED OPTIONS:
+    --config <file>             Config file (default: $XDG_CONFIG_HOME/gorder/config.*
+                                and .gorder.toml/.yaml/.json in the current directory)
     -r, -recursive              Process subdirectories recursively
     -t, -target <dir>           Target directory for organized folders
     -p, --fetch, --flatten      Flatten directory by pulling files from subdirs
     --cleanup                   Remove empty subdirectories (use with -p)
-    -u, -undo                   Undo the last organization operation
+    -u, -undo                   Undo the last organization operation (same as
+                                'gorder undo', honoring --on-conflict)
+
+COMMANDS:
+    apply <plan.json>           Apply a plan written by --save-plan
+    rules test <file>...        Explain which config rule routes each file and why;
+                                takes the organizing options, e.g. -c or --layout
+    history                     List the operations recorded in the undo journal
+    undo [<id>]                 Undo an operation (default: the latest one);
+                                --last N undoes the N latest operations
+    redo [<id>]                 Redo an undone operation (default: the latest undo)
+    cache stats                 Show what the duplicate hash cache holds
+    cache prune                 Drop cached hashes of files that are gone or changed
 
 ANALYSIS & REPORTS:
     -R, --report                Generate detailed directory analysis (gorder_report.md)
     -D, --duplicates            Detect and report duplicate files (gorder_dups.md)
-    --delete-dups               Delete duplicates (use with -D, requires confirmation)
+    --delete-dups               Delete duplicates (use with -D, requires confirmation);
+                                one copy of each group is always kept, and each
+                                other copy is compared with it byte by byte first
+    --keep <policy>             Copy of each group to keep: 'first' (default, first
+                                found), 'oldest', 'newest', 'shortest-path',
+                                'longest-path' or 'first-alpha'
+    --keep-in <list>            Comma-separated directories whose copies are kept
+                                over others, first listed first (e.g. 'Photos/')
+    --prefer-delete <list>      Comma-separated directories whose copies are
+                                deleted first (e.g. 'Downloads/')
+    --hash <algorithm>          Hash used to find duplicates: 'sha256' (default),
+                                'sha1', 'md5', 'blake2b', 'xxh64' or 'crc32c'
+    --verify-dups               Compare duplicates byte by byte to rule out hash
+                                collisions in the report (use with -D)
+    -j, --jobs <n>              Files hashed in parallel (default: number of CPUs)
+    --device-jobs <n>           Files hashed in parallel per device (default: 1 on
+                                spinning disks, --jobs otherwise)
+    --no-cache                  Hash every file again instead of reusing the hashes
+                                cached in $XDG_CACHE_HOME/gorder (use with -D)
 
 EXAMPLES:
     gorder                      # Organize files by extension (default)
     gorder -c                   # Organize by categories
     gorder -d -c                # Preview category organization
+    gorder -c --save-plan p.json  # Save the plan, then: gorder apply p.json
     gorder --date-mode month    # Organize by month
     gorder -r -c                # Recursively organize by categories
     gorder -p --cleanup         # Flatten directory structure
+    gorder -c --mode symlink -t ~/view  # Categorized view, originals untouched
     gorder -R                   # Generate directory report
     gorder -D                   # Find duplicate files
     gorder -u                   # Undo last operation
@@ -102,34 +142,36 @@ For more information, see README.md
 `)
 	}
 
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "apply":
+			runApply(os.Args[2:])
+			return
+		case "rules":
+			runRules(os.Args[2:])
+			return
+		case "history":
+			runHistory(os.Args[2:])
+			return
+		case "undo":
+			runUndo(os.Args[2:])
+			return
+		case "redo":
+			runRedo(os.Args[2:])
+			return
+		case "cache":
+			runCache(os.Args[2:])
+			return
+		}
+	}
+
 	// Flags
 	dryRun := flag.Bool("dry", false, "Show what would happen, but don't move files")
 	flag.BoolVar(dryRun, "d", false, "Show what would happen, but don't move files (shorthand)")
 	flag.BoolVar(dryRun, "dryrun", false, "Show what would happen, but don't move files")
 
-	useFullExt := flag.Bool("full", false, "Use full extension (e.g. .tar.gz) instead of only last extension")
-	flag.BoolVar(useFullExt, "f", false, "Use full extension (e.g. .tar.gz) instead of only last extension (shorthand)")
-
-	useCategories := flag.Bool("categories", false, "Group files by categories instead of individual extensions")
-	flag.BoolVar(useCategories, "c", false, "Group files by categories instead of individual extensions (shorthand)")
-
-	includeList := flag.String("include", "", "Comma-separated list of extensions or patterns to include (e.g., '.,.gitignore')")
-	flag.StringVar(includeList, "i", "", "Comma-separated list of extensions or patterns to include (shorthand)")
-
-	excludeList := flag.String("exclude", "", "Comma-separated list of extensions or patterns to exclude")
-	flag.StringVar(excludeList, "e", "", "Comma-separated list of extensions or patterns to exclude (shorthand)")
-
-	noExtFolder := flag.String("noext-folder", "", "Folder name for files without extensions (e.g., 'NoExtension')")
-
-	caseSensitive := flag.Bool("case-sensitive", false, "Treat extensions as case-sensitive (e.g., .JPG vs .jpg)")
-
-	dateMode := flag.String("date-mode", "", "Group by date: 'year', 'month', 'day', or 'week'")
-
-	recursive := flag.Bool("recursive", false, "Process subdirectories recursively")
-	flag.BoolVar(recursive, "r", false, "Process subdirectories recursively (shorthand)")
-
-	targetDir := flag.String("target", ".", "Target directory for organized folders")
-	flag.StringVar(targetDir, "t", ".", "Target directory for organized folders (shorthand)")
+	of := addOrganizeFlags(flag.CommandLine)
 
 	undo := flag.Bool("undo", false, "Undo the last organization operation")
 	flag.BoolVar(undo, "u", false, "Undo the last organization operation (shorthand)")
@@ -140,858 +182,517 @@ For more information, see README.md
 
 	cleanup := flag.Bool("cleanup", false, "Remove empty subdirectories after fetch operation (use with --fetch)")
 
-	quiet := flag.Bool("quiet", false, "Use simple folder names without gorder_ prefix (e.g., 'jpg' instead of 'gorder_jpg')")
-	flag.BoolVar(quiet, "q", false, "Use simple folder names without gorder_ prefix (shorthand)")
-
 	report := flag.Bool("report", false, "Generate a detailed report (gorder_report.md) of directory contents")
 	flag.BoolVar(report, "R", false, "Generate a detailed report (gorder_report.md) of directory contents (shorthand)")
 
 	duplicates := flag.Bool("duplicates", false, "Detect and report duplicate files (gorder_dups.md)")
 	flag.BoolVar(duplicates, "D", false, "Detect and report duplicate files (shorthand)")
 
-	deleteDups := flag.Bool("delete-dups", false, "Delete duplicate files (use with --duplicates, keeps first instance)")
+	deleteDups := flag.Bool("delete-dups", false, "Delete duplicate files (use with --duplicates, keeps one copy of each group)")
+
+	keep := flag.String("keep", organizer.KeepFirst, "Copy of each duplicate group to keep: "+strings.Join(organizer.KeepPolicies(), ", "))
+
+	keepIn := flag.String("keep-in", "", "Comma-separated directories whose duplicates are kept over others (e.g. 'Photos/')")
+
+	preferDelete := flag.String("prefer-delete", "", "Comma-separated directories whose duplicates are deleted first (e.g. 'Downloads/')")
+
+	jobs := flag.Int("jobs", 0, "Number of files hashed in parallel (default: number of CPUs)")
+	flag.IntVar(jobs, "j", 0, "Number of files hashed in parallel (shorthand)")
+
+	deviceJobs := flag.Int("device-jobs", 0, "Files hashed in parallel per device (default: 1 on spinning disks, --jobs otherwise)")
+
+	hashAlgo := flag.String("hash", organizer.HashSHA256, "Hash algorithm for duplicate detection: "+strings.Join(organizer.HashAlgorithms(), ", "))
+
+	verifyDups := flag.Bool("verify-dups", false, "Compare duplicates byte by byte after hashing (use with --duplicates)")
+
+	noCache := flag.Bool("no-cache", false, "Do not use or update the hash cache (use with --duplicates)")
+
+	savePlan := flag.String("save-plan", "", "Write the planned moves as JSON to this file instead of moving files")
 
 	flag.Parse()
 
+	ctx := context.Background()
+
 	// Handle undo mode
 	if *undo {
-		performUndo()
+		// --on-conflict defaults to rename for organizing but to skip for
+		// undo, so it is only passed on when given
+		opts := organizer.UndoOptions{OnConflict: organizer.ConflictSkip, Prompt: promptConflict}
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "on-conflict" {
+				opts.OnConflict = *of.onConflict
+			}
+		})
+		if _, err := performUndo(ctx, opts); err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
 
 	// Handle fetch mode
 	if *fetch {
-		performFetch(*dryRun, *cleanup)
+		performFetch(ctx, *dryRun, organizer.FetchOptions{
+			Cleanup:     *cleanup,
+			Mode:        *of.mode,
+			OnConflict:  *of.onConflict,
+			ExcludeDirs: organizer.ParseList(*of.excludeDirs),
+		})
 		return
 	}
 
 	// Handle report generation
 	if *report {
-		generateReport()
+		generateReport(ctx, organizer.ScanOptions{ExcludeDirs: organizer.ParseList(*of.excludeDirs)})
 		return
 	}
 
 	// Handle duplicate detection
 	if *duplicates {
-		findDuplicates(*deleteDups)
-		return
-	}
-
-	// Initialize log file for undo functionality
-	if !*dryRun {
-		var err error
-		logFile, err = os.Create(".gorder_log.txt")
+		hasher, err := organizer.NewHasher(*hashAlgo)
 		if err != nil {
-			log.Printf("Warning: Could not create log file: %v", err)
-		} else {
-			defer logFile.Close()
+			log.Fatal(err)
 		}
-	}
-
-	// Parse include/exclude lists
-	includeSet := parseList(*includeList)
-	excludeSet := parseList(*excludeList)
-
-	// Build extension to category map if using categories
-	extToCat := make(map[string]string)
-	if *useCategories {
-		for category, exts := range categoryMap {
-			for _, ext := range exts {
-				extToCat[ext] = category
+		var cache *organizer.HashCache
+		if !*noCache {
+			// Without a cache every file is simply hashed again
+			if cache, err = organizer.OpenHashCache(""); err != nil {
+				log.Printf("Not using the hash cache: %v\n", err)
 			}
 		}
+		findDuplicates(ctx, *deleteDups, organizer.DuplicateOptions{
+			ExcludeDirs:  organizer.ParseList(*of.excludeDirs),
+			Verify:       *verifyDups,
+			Jobs:         *jobs,
+			DeviceJobs:   *deviceJobs,
+			Hasher:       hasher,
+			Cache:        cache,
+			Keep:         *keep,
+			KeepIn:       organizer.ParseList(*keepIn),
+			PreferDelete: organizer.ParseList(*preferDelete),
+		})
+		return
 	}
 
-	// Process directory
-	if *recursive {
-		processDirectoryRecursive(".", *dryRun, *useFullExt, *useCategories, *caseSensitive, *dateMode, *noExtFolder, includeSet, excludeSet, extToCat, *targetDir, *quiet)
-	} else {
-		processDirectory(".", *dryRun, *useFullExt, *useCategories, *caseSensitive, *dateMode, *noExtFolder, includeSet, excludeSet, extToCat, *targetDir, *quiet)
+	org, err := organizer.New(of.options())
+	if err != nil {
+		log.Fatal(err)
 	}
-}
 
-func processDirectory(dir string, dryRun, useFullExt, useCategories, caseSensitive bool, dateMode, noExtFolder string, includeSet, excludeSet map[string]bool, extToCat map[string]string, targetDir string, quiet bool) {
-	files, err := os.ReadDir(dir)
+	if *dryRun || *savePlan != "" {
+		plan, err := org.Plan(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *savePlan != "" {
+			writePlan(plan, *savePlan)
+			return
+		}
+		printPlan(plan)
+		return
+	}
+
+	res, err := org.Apply(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	printResult(res)
+}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
+// organizeFlags are the flags deciding where files go, shared by the
+// main command and "rules test" so both plan the same destinations
+type organizeFlags struct {
+	fullExt, categories, caseSensitive, recursive, quiet *bool
 
-		name := file.Name()
+	include, exclude, excludeDirs, noExtFolder              *string
+	dateMode, dateFormat, timeZone, fiscalStart, dateSource *string
+	groupBy, layout, config, target, mode, onConflict       *string
+}
 
-		// Skip log files
-		if name == ".gorder_log.txt" {
-			continue
-		}
+// addOrganizeFlags defines the organizing flags on fs
+func addOrganizeFlags(fs *flag.FlagSet) *organizeFlags {
+	f := &organizeFlags{}
 
-		// Check if file should be processed based on include/exclude
-		if !shouldProcess(name, includeSet, excludeSet) {
-			continue
-		}
+	f.fullExt = fs.Bool("full", false, "Use full extension (e.g. .tar.gz) instead of only last extension")
+	fs.BoolVar(f.fullExt, "f", false, "Use full extension (e.g. .tar.gz) instead of only last extension (shorthand)")
 
-		// Get file info for date-based grouping
-		var folderName string
-		if dateMode != "" {
-			info, err := file.Info()
-			if err != nil {
-				log.Printf("Cannot get file info for %s: %v\n", name, err)
-				continue
-			}
-			folderName = getDateFolder(info.ModTime(), dateMode)
-		} else {
-			ext := getExtension(name, useFullExt)
-
-			// Handle files without extension
-			if ext == "" {
-				if noExtFolder != "" {
-					folderName = noExtFolder
-				} else {
-					continue
-				}
-			} else {
-				if !caseSensitive {
-					ext = strings.ToLower(ext)
-				}
-
-				// Determine folder name
-				if useCategories {
-					if cat, ok := extToCat[strings.ToLower(ext)]; ok {
-						folderName = cat
-					} else {
-						folderName = ext
-					}
-				} else {
-					if quiet {
-						folderName = ext
-					} else {
-						folderName = fmt.Sprintf("gorder_%s", ext)
-					}
-				}
-			}
-		}
+	f.categories = fs.Bool("categories", false, "Group files by categories instead of individual extensions")
+	fs.BoolVar(f.categories, "c", false, "Group files by categories instead of individual extensions (shorthand)")
 
-		// Create folder if needed
-		fullFolderPath := filepath.Join(targetDir, folderName)
-		if _, err := os.Stat(fullFolderPath); errors.Is(err, os.ErrNotExist) {
-			if !dryRun {
-				if err := os.MkdirAll(fullFolderPath, 0755); err != nil {
-					log.Printf("Cannot create folder %s: %v\n", fullFolderPath, err)
-					continue
-				}
-			}
-			fmt.Printf("[+] Created folder: %s\n", fullFolderPath)
-		}
+	f.include = fs.String("include", "", "Comma-separated list of extensions or patterns to include (e.g., '.,.gitignore')")
+	fs.StringVar(f.include, "i", "", "Comma-separated list of extensions or patterns to include (shorthand)")
 
-		oldPath := filepath.Join(dir, name)
-		newPath := filepath.Join(fullFolderPath, name)
+	f.exclude = fs.String("exclude", "", "Comma-separated list of extensions or patterns to exclude")
+	fs.StringVar(f.exclude, "e", "", "Comma-separated list of extensions or patterns to exclude (shorthand)")
 
-		// Handle name collision
-		newPath = avoidCollision(newPath)
+	f.excludeDirs = fs.String("exclude-dir", "", "Comma-separated globs of directories to skip entirely (e.g., 'node_modules,build')")
 
-		if dryRun {
-			fmt.Printf("[DRY] Would move %s → %s\n", oldPath, newPath)
-			continue
-		}
+	f.noExtFolder = fs.String("noext-folder", "", "Folder name for files without extensions (e.g., 'NoExtension')")
 
-		if err := os.Rename(oldPath, newPath); err != nil {
-			log.Printf("Error moving %s: %v\n", oldPath, err)
-		} else {
-			fmt.Printf("Moved %s → %s\n", oldPath, newPath)
-			// Log for undo
-			if logFile != nil {
-				fmt.Fprintf(logFile, "%s|%s\n", newPath, oldPath)
-			}
-		}
-	}
-}
+	f.caseSensitive = fs.Bool("case-sensitive", false, "Treat extensions as case-sensitive (e.g., .JPG vs .jpg)")
 
-func processDirectoryRecursive(dir string, dryRun, useFullExt, useCategories, caseSensitive bool, dateMode, noExtFolder string, includeSet, excludeSet map[string]bool, extToCat map[string]string, targetDir string, quiet bool) {
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	f.dateMode = fs.String("date-mode", "", "Group by date: 'year', 'quarter', 'month', 'week', 'day', 'fiscal-year' or 'decade', nested with slashes (e.g. 'year/month')")
 
-		if info.IsDir() {
-			return nil
-		}
+	f.dateFormat = fs.String("date-format", "", "Name date folders with a Go layout ('2006/01') or strftime format ('%Y/%m')")
 
-		name := info.Name()
+	f.timeZone = fs.String("tz", "", "Time zone to group dates in, an IANA name such as 'Europe/Berlin' or 'UTC' (default: local time)")
 
-		// Skip log files and files in target directory
-		if name == ".gorder_log.txt" || strings.HasPrefix(path, targetDir+string(os.PathSeparator)) {
-			return nil
-		}
+	f.fiscalStart = fs.String("fiscal-year-start", "", "First month of the fiscal year for --date-mode fiscal-year (e.g. 'apr' or 4)")
 
-		// Check if file should be processed
-		if !shouldProcess(name, includeSet, excludeSet) {
-			return nil
-		}
+	f.dateSource = fs.String("date-source", organizer.DateSourceAuto, "Where dates come from: 'auto', 'exif', 'media', 'mtime', 'ctime', 'birth' or 'filename'")
 
-		// Get folder name based on mode
-		var folderName string
-		if dateMode != "" {
-			folderName = getDateFolder(info.ModTime(), dateMode)
-		} else {
-			ext := getExtension(name, useFullExt)
-
-			if ext == "" {
-				if noExtFolder != "" {
-					folderName = noExtFolder
-				} else {
-					return nil
-				}
-			} else {
-				if !caseSensitive {
-					ext = strings.ToLower(ext)
-				}
-
-				if useCategories {
-					if cat, ok := extToCat[strings.ToLower(ext)]; ok {
-						folderName = cat
-					} else {
-						folderName = ext
-					}
-				} else {
-					if quiet {
-						folderName = ext
-					} else {
-						folderName = fmt.Sprintf("gorder_%s", ext)
-					}
-				}
-			}
-		}
+	f.groupBy = fs.String("group-by", "", "Strategies to group by, nested in order (e.g. 'category/date')")
 
-		// Create folder if needed
-		fullFolderPath := filepath.Join(targetDir, folderName)
-		if _, err := os.Stat(fullFolderPath); errors.Is(err, os.ErrNotExist) {
-			if !dryRun {
-				if err := os.MkdirAll(fullFolderPath, 0755); err != nil {
-					log.Printf("Cannot create folder %s: %v\n", fullFolderPath, err)
-					return nil
-				}
-			}
-			fmt.Printf("[+] Created folder: %s\n", fullFolderPath)
-		}
+	f.layout = fs.String("layout", "", "Destination template, e.g. '{category}/{year}/{month}/{name}'")
 
-		newPath := filepath.Join(fullFolderPath, name)
+	f.config = fs.String("config", "", "Config file to use instead of the user and project config files")
 
-		// Handle name collision
-		newPath = avoidCollision(newPath)
+	f.recursive = fs.Bool("recursive", false, "Process subdirectories recursively")
+	fs.BoolVar(f.recursive, "r", false, "Process subdirectories recursively (shorthand)")
 
-		if dryRun {
-			fmt.Printf("[DRY] Would move %s → %s\n", path, newPath)
-			return nil
-		}
+	f.target = fs.String("target", ".", "Target directory for organized folders")
+	fs.StringVar(f.target, "t", ".", "Target directory for organized folders (shorthand)")
 
-		if err := os.Rename(path, newPath); err != nil {
-			log.Printf("Error moving %s: %v\n", path, err)
-		} else {
-			fmt.Printf("Moved %s → %s\n", path, newPath)
-			// Log for undo
-			if logFile != nil {
-				fmt.Fprintf(logFile, "%s|%s\n", newPath, path)
-			}
-		}
+	f.quiet = fs.Bool("quiet", false, "Use simple folder names without gorder_ prefix (e.g., 'jpg' instead of 'gorder_jpg')")
+	fs.BoolVar(f.quiet, "q", false, "Use simple folder names without gorder_ prefix (shorthand)")
 
-		return nil
-	})
+	f.mode = fs.String("mode", organizer.OpMove, "How files are placed: 'move', 'copy', 'hardlink' or 'symlink'")
 
+	f.onConflict = fs.String("on-conflict", organizer.ConflictRename, "When a destination exists: 'rename', 'skip', 'overwrite', 'keep-newer', 'keep-larger' or 'dedupe'")
+
+	return f
+}
+
+// options returns the Options for organizing the current directory as
+// the flags and the config files ask
+func (f *organizeFlags) options() organizer.Options {
+	cfg := loadConfig(*f.config)
+	categories, err := cfg.CategoryMap()
 	if err != nil {
-		log.Printf("Error walking directory: %v", err)
+		log.Fatal(err)
+	}
+	return organizer.Options{
+		Dir:             ".",
+		Target:          *f.target,
+		Recursive:       *f.recursive,
+		Mode:            *f.mode,
+		OnConflict:      *f.onConflict,
+		FullExt:         *f.fullExt,
+		Categories:      *f.categories,
+		CaseSensitive:   *f.caseSensitive,
+		DateMode:        *f.dateMode,
+		DateFormat:      *f.dateFormat,
+		FiscalYearStart: parseFiscalStart(*f.fiscalStart),
+		TimeZone:        parseTimeZone(*f.timeZone),
+		DateSource:      *f.dateSource,
+		NoExtFolder:     *f.noExtFolder,
+		Include:         organizer.ParseList(*f.include),
+		Exclude:         organizer.ParseList(*f.exclude),
+		ExcludeDirs:     organizer.ParseList(*f.excludeDirs),
+		Quiet:           *f.quiet,
+		GroupBy:         *f.groupBy,
+		Layout:          *f.layout,
+		CategoryMap:     categories,
+		Rules:           cfg.Rules(),
+		DatePatterns:    cfg.DatePatterns(),
 	}
 }
 
-func getExtension(name string, full bool) string {
-	if full {
-		parts := strings.Split(name, ".")
-		if len(parts) > 2 {
-			return strings.Join(parts[len(parts)-2:], ".")
-		}
+// parseFiscalStart parses the --fiscal-year-start month, January when
+// empty
+func parseFiscalStart(s string) time.Month {
+	if s == "" {
+		return time.January
+	}
+	month, err := organizer.ParseMonth(s)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return strings.TrimPrefix(filepath.Ext(name), ".")
+	return month
 }
 
-func avoidCollision(path string) string {
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		return path
+// parseTimeZone loads the --tz zone, nil for local time when empty
+func parseTimeZone(name string) *time.Location {
+	if name == "" {
+		return nil
 	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Fatalf("invalid time zone %q: %v", name, err)
+	}
+	return loc
+}
 
-	ext := filepath.Ext(path)
-	base := strings.TrimSuffix(filepath.Base(path), ext)
-	dir := filepath.Dir(path)
-
-	// Try sequential numbering
-	for i := 1; ; i++ {
-		newName := fmt.Sprintf("%s (%d)%s", base, i, ext)
-		newPath := filepath.Join(dir, newName)
-		if _, err := os.Stat(newPath); errors.Is(err, os.ErrNotExist) {
-			return newPath
+// loadConfig loads the config file at path, or the user and project
+// config files if path is empty
+func loadConfig(path string) *organizer.Config {
+	paths := []string{path}
+	if path == "" {
+		var err error
+		paths, err = organizer.ConfigPaths(".")
+		if err != nil {
+			log.Fatal(err)
 		}
 	}
+	cfg, err := organizer.LoadConfig(paths...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
 }
 
-func shouldProcess(name string, includeSet, excludeSet map[string]bool) bool {
-	// Skip hidden files by default unless explicitly included
-	if strings.HasPrefix(name, ".") {
-		if len(includeSet) == 0 || !includeSet[name] {
-			return false
+func printPlan(plan *organizer.Plan) {
+	for _, folder := range plan.Folders {
+		fmt.Printf("[DRY] Would create folder: %s\n", folder)
+	}
+	for _, move := range plan.Moves {
+		if move.Reason != "" {
+			fmt.Printf("[DRY] Would %s %s → %s (%s)\n", opVerb(move.Op), move.Source, move.Dest, move.Reason)
+		} else {
+			fmt.Printf("[DRY] Would %s %s → %s\n", opVerb(move.Op), move.Source, move.Dest)
 		}
 	}
-
-	// Check exclude list
-	ext := filepath.Ext(name)
-	if excludeSet[ext] || excludeSet[name] {
-		return false
+	for _, skip := range plan.Skipped {
+		fmt.Printf("[DRY] Would skip %s: %s\n", skip.Path, skip.Reason)
 	}
+}
 
-	// If include list is specified, only process included files
-	if len(includeSet) > 0 {
-		return includeSet[ext] || includeSet[name] || includeSet["."]
+// opVerb returns the verb describing a planned operation
+func opVerb(op string) string {
+	switch op {
+	case "":
+		return organizer.OpMove
+	case organizer.OpHardlink:
+		return "hard-link"
 	}
+	return op
+}
 
-	return true
+// opDone returns the past tense of an operation, as printed for each file
+func opDone(op string) string {
+	switch op {
+	case organizer.OpCopy:
+		return "Copied"
+	case organizer.OpHardlink:
+		return "Hard-linked"
+	case organizer.OpSymlink:
+		return "Symlinked"
+	case organizer.OpTrash:
+		return "Trashed"
+	}
+	return "Moved"
 }
 
-func parseList(list string) map[string]bool {
-	result := make(map[string]bool)
-	if list == "" {
-		return result
+func writePlan(plan *organizer.Plan, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal("Error creating plan file:", err)
 	}
+	defer f.Close()
 
-	items := strings.Split(list, ",")
-	for _, item := range items {
-		item = strings.TrimSpace(item)
-		if item != "" {
-			result[item] = true
-		}
+	if err := plan.WriteJSON(f); err != nil {
+		log.Fatal("Error writing plan file:", err)
 	}
-	return result
+	fmt.Printf("Plan with %d moves written to %s\n", len(plan.Moves), path)
+	fmt.Printf("Review or edit it, then run: gorder apply %s\n", path)
 }
 
-func getDateFolder(modTime time.Time, mode string) string {
-	switch mode {
-	case "year":
-		return fmt.Sprintf("%d", modTime.Year())
-	case "month":
-		return fmt.Sprintf("%04d-%02d", modTime.Year(), modTime.Month())
-	case "day":
-		return fmt.Sprintf("%04d-%02d-%02d", modTime.Year(), modTime.Month(), modTime.Day())
-	case "week":
-		_, week := modTime.ISOWeek()
-		return fmt.Sprintf("Week_%02d", week)
-	default:
-		return "Unknown"
+func printResult(res *organizer.Result) {
+	for _, folder := range res.Created {
+		fmt.Printf("[+] Created folder: %s\n", folder)
 	}
-}
-
-func performUndo() {
-	// Read the log file
-	file, err := os.Open(".gorder_log.txt")
-	if err != nil {
-		log.Fatal("Cannot open log file. No previous operation to undo.")
+	for _, move := range res.Moved {
+		fmt.Printf("%s %s → %s\n", opDone(move.Op), move.Source, move.Dest)
 	}
-	defer file.Close()
-
-	var actions []moveAction
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "|")
-		if len(parts) == 2 {
-			actions = append(actions, moveAction{from: parts[0], to: parts[1]})
-		}
+	for _, failure := range res.Failed {
+		log.Printf("Error %sing %s: %v\n", strings.TrimSuffix(opVerb(failure.Op), "e"), failure.Source, failure.Err)
+	}
+	for _, err := range res.Errors {
+		log.Println(err)
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal("Error reading log file:", err)
+// performUndo undoes an operation and prints the files it restored
+func performUndo(ctx context.Context, opts organizer.UndoOptions) (*organizer.Result, error) {
+	res, err := organizer.Undo(ctx, ".", opts)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(actions) == 0 {
+	total := len(res.Moved) + len(res.Failed)
+	if total == 0 {
 		fmt.Println("No actions to undo.")
-		return
+		return res, nil
 	}
 
-	fmt.Printf("Undoing %d file moves...\n", len(actions))
-
-	successCount := 0
-	for _, action := range actions {
-		if err := os.Rename(action.from, action.to); err != nil {
-			log.Printf("Error moving %s back to %s: %v\n", action.from, action.to, err)
-		} else {
-			fmt.Printf("Restored %s → %s\n", action.from, action.to)
-			successCount++
+	fmt.Printf("Undoing %d file moves...\n", total)
+	for _, dir := range res.Created {
+		fmt.Printf("[+] Recreated folder: %s\n", dir)
+	}
+	for _, move := range res.Moved {
+		switch move.Op {
+		case organizer.OpCopy:
+			fmt.Printf("Removed copy %s of %s\n", move.Source, move.Dest)
+		case organizer.OpHardlink, organizer.OpSymlink:
+			fmt.Printf("Removed link %s to %s\n", move.Source, move.Dest)
+		default:
+			fmt.Printf("Restored %s → %s\n", move.Source, move.Dest)
 		}
 	}
-
-	fmt.Printf("\nUndo complete: %d/%d files restored.\n", successCount, len(actions))
-
-	// Remove the log file after successful undo
-	if successCount > 0 {
-		os.Remove(".gorder_log.txt")
+	for _, failure := range res.Failed {
+		log.Printf("Error moving %s back to %s: %v\n", failure.Source, failure.Dest, failure.Err)
+	}
+	for _, dir := range res.Removed {
+		fmt.Printf("[-] Removed empty folder: %s\n", dir)
 	}
+	for _, err := range res.Errors {
+		log.Println(err)
+	}
+
+	fmt.Printf("\nUndo complete: %d/%d files restored.\n", len(res.Moved), total)
+	return res, nil
 }
 
-func performFetch(dryRun, cleanup bool) {
+func performFetch(ctx context.Context, dryRun bool, opts organizer.FetchOptions) {
 	fmt.Println("Fetching files from subdirectories...")
 
-	var filesToMove []moveAction
-
-	// Walk through all subdirectories
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip the current directory
-		if path == "." {
-			return nil
-		}
-
-		// Skip the log file
-		if filepath.Base(path) == ".gorder_log.txt" {
-			return nil
-		}
-
-		// If it's a file and not in current directory, add to move list
-		if !info.IsDir() {
-			// Check if file is in a subdirectory
-			dir := filepath.Dir(path)
-			if dir != "." {
-				// Target is current directory with collision avoidance
-				targetPath := filepath.Join(".", filepath.Base(path))
-				filesToMove = append(filesToMove, moveAction{
-					from: path,
-					to:   targetPath,
-				})
-			}
-		}
-
-		return nil
-	})
-
+	plan, err := organizer.PlanFetch(ctx, ".", opts)
 	if err != nil {
-		log.Fatal("Error walking directory:", err)
+		log.Fatal(err)
 	}
 
-	if len(filesToMove) == 0 {
+	if len(plan.Moves) == 0 {
 		fmt.Println("No files found in subdirectories.")
 		return
 	}
 
-	fmt.Printf("Found %d files in subdirectories\n", len(filesToMove))
-
-	// Move files
-	successCount := 0
-	for _, action := range filesToMove {
-		// Apply collision avoidance
-		finalPath := avoidCollision(action.to)
+	fmt.Printf("Found %d files in subdirectories\n", len(plan.Moves))
 
-		if dryRun {
-			fmt.Printf("[DRY] Would move %s → %s\n", action.from, finalPath)
-			successCount++
-		} else {
-			if err := os.Rename(action.from, finalPath); err != nil {
-				log.Printf("Error moving %s: %v\n", action.from, err)
-			} else {
-				fmt.Printf("Moved %s → %s\n", action.from, finalPath)
-				successCount++
-			}
+	if dryRun {
+		printPlan(plan)
+		fmt.Printf("\nFetch complete: %d/%d files %s to current directory\n", len(plan.Moves), len(plan.Moves), strings.ToLower(opDone(opts.Mode)))
+		if opts.Cleanup {
+			fmt.Println("\n[DRY] Would clean up empty directories")
 		}
+		return
 	}
 
-	fmt.Printf("\nFetch complete: %d/%d files moved to current directory\n", successCount, len(filesToMove))
-
-	// Cleanup empty directories if requested
-	if cleanup && !dryRun {
-		fmt.Println("\nCleaning up empty directories...")
-		removeEmptyDirs(".")
-	} else if cleanup && dryRun {
-		fmt.Println("\n[DRY] Would clean up empty directories")
+	res, err := organizer.Fetch(ctx, ".", opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, move := range res.Moved {
+		fmt.Printf("%s %s → %s\n", opDone(move.Op), move.Source, move.Dest)
+	}
+	for _, failure := range res.Failed {
+		log.Printf("Error %sing %s: %v\n", strings.TrimSuffix(opVerb(failure.Op), "e"), failure.Source, failure.Err)
 	}
-}
-
-func removeEmptyDirs(root string) {
-	// Walk bottom-up to remove nested empty directories
-	var dirs []string
-
-	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() && path != "." {
-			dirs = append(dirs, path)
-		}
-
-		return nil
-	})
 
-	// Reverse order to process deepest directories first
-	for i := len(dirs) - 1; i >= 0; i-- {
-		dir := dirs[i]
+	fmt.Printf("\nFetch complete: %d/%d files %s to current directory\n", len(res.Moved), len(res.Moved)+len(res.Failed), strings.ToLower(opDone(opts.Mode)))
 
-		// Check if directory is empty
-		entries, err := os.ReadDir(dir)
-		if err != nil {
-			log.Printf("Error reading directory %s: %v\n", dir, err)
-			continue
+	if opts.Cleanup {
+		fmt.Println("\nCleaning up empty directories...")
+		for _, dir := range res.Removed {
+			fmt.Printf("Removed empty directory: %s\n", dir)
 		}
-
-		if len(entries) == 0 {
-			if err := os.Remove(dir); err != nil {
-				log.Printf("Error removing empty directory %s: %v\n", dir, err)
-			} else {
-				fmt.Printf("Removed empty directory: %s\n", dir)
-			}
+		for _, err := range res.Errors {
+			log.Println(err)
 		}
 	}
 }
 
-type fileInfo struct {
-	path string
-	size int64
-	ext  string
-}
-
-type extStats struct {
-	count int
-	size  int64
-}
-
-func generateReport() {
+func generateReport(ctx context.Context, opts organizer.ScanOptions) {
 	fmt.Println("Generating directory report...")
 
-	var allFiles []fileInfo
-	extMap := make(map[string]*extStats)
-	var totalSize int64
-	var totalDirs int
-	var hiddenFiles int
-	var noExtFiles int
-
-	// Walk through all files and directories
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			if path != "." {
-				totalDirs++
-			}
-			return nil
-		}
-
-		// Track hidden files
-		if strings.HasPrefix(filepath.Base(path), ".") {
-			hiddenFiles++
-			return nil
-		}
-
-		ext := filepath.Ext(path)
-		if ext == "" {
-			noExtFiles++
-			ext = "(no extension)"
-		} else {
-			ext = strings.ToLower(ext)
-		}
-
-		size := info.Size()
-		totalSize += size
-
-		// Track file
-		allFiles = append(allFiles, fileInfo{
-			path: path,
-			size: size,
-			ext:  ext,
-		})
-
-		// Track extension stats
-		if _, ok := extMap[ext]; !ok {
-			extMap[ext] = &extStats{}
-		}
-		extMap[ext].count++
-		extMap[ext].size += size
-
-		return nil
-	})
-
+	report, err := organizer.Scan(ctx, ".", opts)
 	if err != nil {
-		log.Fatal("Error scanning directory:", err)
-	}
-
-	// Sort files by size (largest first)
-	sort.Slice(allFiles, func(i, j int) bool {
-		return allFiles[i].size > allFiles[j].size
-	})
-
-	// Sort extensions by total size (for bar chart)
-	type extEntry struct {
-		ext   string
-		stats *extStats
-	}
-	var extList []extEntry
-	for ext, stats := range extMap {
-		extList = append(extList, extEntry{ext, stats})
+		log.Fatal(err)
 	}
-	sort.Slice(extList, func(i, j int) bool {
-		return extList[i].stats.size > extList[j].stats.size
-	})
 
-	// Generate report
-	reportFile, err := os.Create("gorder_report.md")
+	reportFile, err := os.Create(organizer.ReportName)
 	if err != nil {
 		log.Fatal("Error creating report file:", err)
 	}
 	defer reportFile.Close()
 
-	w := bufio.NewWriter(reportFile)
-	defer w.Flush()
-
-	// Header
-	fmt.Fprintf(w, "# Gorder Directory Report\n\n")
-	fmt.Fprintf(w, "Generated: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Fprintf(w, "---\n\n")
-
-	// Directory Summary
-	fmt.Fprintf(w, "## 📊 Directory Summary\n\n")
-	fmt.Fprintf(w, "- **Total Files**: %d\n", len(allFiles))
-	fmt.Fprintf(w, "- **Total Size**: %s\n", formatSize(totalSize))
-	fmt.Fprintf(w, "- **Total Directories**: %d\n", totalDirs)
-	fmt.Fprintf(w, "- **Hidden Files**: %d (skipped from analysis)\n", hiddenFiles)
-	fmt.Fprintf(w, "- **Files Without Extension**: %d\n\n", noExtFiles)
-
-	// File Type Distribution
-	fmt.Fprintf(w, "## 📁 File Type Distribution\n\n")
-	fmt.Fprintf(w, "| Extension | Count | Total Size |\n")
-	fmt.Fprintf(w, "|-----------|-------|------------|\n")
-	for _, entry := range extList {
-		fmt.Fprintf(w, "| %s | %d | %s |\n", entry.ext, entry.stats.count, formatSize(entry.stats.size))
-	}
-	fmt.Fprintf(w, "\n")
-
-	// Top 5 File Types Bar Chart
-	if len(extList) > 0 {
-		fmt.Fprintf(w, "## 📈 Top 5 File Types (by size)\n\n")
-		maxWidth := 50
-		topN := 5
-		if len(extList) < topN {
-			topN = len(extList)
-		}
-		maxSize := extList[0].stats.size
-		for i := 0; i < topN; i++ {
-			entry := extList[i]
-			barWidth := int(float64(entry.stats.size) / float64(maxSize) * float64(maxWidth))
-			if barWidth == 0 && entry.stats.size > 0 {
-				barWidth = 1
-			}
-			bar := strings.Repeat("█", barWidth)
-			fmt.Fprintf(w, "%15s | %-50s %s\n", entry.ext, bar, formatSize(entry.stats.size))
-		}
-		fmt.Fprintf(w, "\n")
-	}
-
-	// Top 10 Largest Files
-	fmt.Fprintf(w, "## 📦 Top 10 Largest Files\n\n")
-	fmt.Fprintf(w, "| # | File Path | Size |\n")
-	fmt.Fprintf(w, "|---|-----------|------|\n")
-	topFiles := 10
-	if len(allFiles) < topFiles {
-		topFiles = len(allFiles)
-	}
-	for i := 0; i < topFiles; i++ {
-		file := allFiles[i]
-		fmt.Fprintf(w, "| %d | %s | %s |\n", i+1, file.path, formatSize(file.size))
+	if err := report.WriteMarkdown(reportFile); err != nil {
+		log.Fatal("Error writing report file:", err)
 	}
 
-	fmt.Printf("\n✅ Report generated: gorder_report.md\n")
-	fmt.Printf("   Total files analyzed: %d\n", len(allFiles))
-	fmt.Printf("   Total size: %s\n", formatSize(totalSize))
-}
-
-func formatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	fmt.Printf("\n✅ Report generated: %s\n", organizer.ReportName)
+	fmt.Printf("   Total files analyzed: %d\n", len(report.Files))
+	fmt.Printf("   Total size: %s\n", organizer.FormatSize(report.TotalSize))
 }
 
-func findDuplicates(deleteDups bool) {
+func findDuplicates(ctx context.Context, deleteDups bool, opts organizer.DuplicateOptions) {
 	fmt.Println("Scanning for duplicate files...")
 
-	type fileHash struct {
-		path string
-		size int64
-	}
-
-	hashMap := make(map[string][]fileHash)
-	var totalFiles int
-	var totalSize int64
-
-	// Calculate hash for all files
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			return nil
-		}
-
-		// Skip hidden files
-		if strings.HasPrefix(filepath.Base(path), ".") {
-			return nil
-		}
-
-		// Skip report files
-		if filepath.Base(path) == "gorder_report.md" || filepath.Base(path) == "gorder_dups.md" {
-			return nil
-		}
-
-		totalFiles++
-		totalSize += info.Size()
-
-		// Calculate MD5 hash
-		hash, err := hashFile(path)
-		if err != nil {
-			log.Printf("Error hashing %s: %v\n", path, err)
-			return nil
+	// Ctrl-C stops hashing but keeps what was hashed so far in the cache
+	scanCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	report, err := organizer.FindDuplicates(scanCtx, ".", opts)
+	stop()
+	if opts.Cache != nil {
+		if err := opts.Cache.Save(); err != nil {
+			log.Printf("Error saving the hash cache: %v\n", err)
 		}
-
-		hashMap[hash] = append(hashMap[hash], fileHash{
-			path: path,
-			size: info.Size(),
-		})
-
-		return nil
-	})
-
+	}
 	if err != nil {
-		log.Fatal("Error scanning for duplicates:", err)
+		log.Fatal(err)
 	}
-
-	// Find duplicates (hashes with more than one file)
-	var duplicateGroups [][]fileHash
-	var duplicateCount int
-	var duplicateSize int64
-
-	for _, files := range hashMap {
-		if len(files) > 1 {
-			duplicateGroups = append(duplicateGroups, files)
-			duplicateCount += len(files) - 1 // Don't count the first instance
-			for i := 1; i < len(files); i++ {
-				duplicateSize += files[i].size
-			}
-		}
+	for _, err := range report.Errors {
+		log.Println(err)
 	}
 
-	if len(duplicateGroups) == 0 {
+	if len(report.Groups) == 0 {
 		fmt.Println("\n✅ No duplicate files found!")
 		return
 	}
 
-	// Sort groups by size (largest first)
-	sort.Slice(duplicateGroups, func(i, j int) bool {
-		return duplicateGroups[i][0].size > duplicateGroups[j][0].size
-	})
-
-	// Generate report
-	reportFile, err := os.Create("gorder_dups.md")
+	reportFile, err := os.Create(organizer.DupsReportName)
 	if err != nil {
 		log.Fatal("Error creating duplicates report:", err)
 	}
 	defer reportFile.Close()
 
-	w := bufio.NewWriter(reportFile)
-	defer w.Flush()
-
-	// Header
-	fmt.Fprintf(w, "# Gorder Duplicate Files Report\n\n")
-	fmt.Fprintf(w, "Generated: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Fprintf(w, "---\n\n")
-
-	// Summary
-	fmt.Fprintf(w, "## 📊 Summary\n\n")
-	fmt.Fprintf(w, "- **Total Files Scanned**: %d\n", totalFiles)
-	fmt.Fprintf(w, "- **Duplicate Groups**: %d\n", len(duplicateGroups))
-	fmt.Fprintf(w, "- **Duplicate Files**: %d\n", duplicateCount)
-	fmt.Fprintf(w, "- **Wasted Space**: %s\n\n", formatSize(duplicateSize))
-
-	// Duplicate Groups
-	fmt.Fprintf(w, "## 🔍 Duplicate Groups\n\n")
-
-	for i, group := range duplicateGroups {
-		fmt.Fprintf(w, "### Group %d (Size: %s, %d copies)\n\n", i+1, formatSize(group[0].size), len(group))
-		for j, file := range group {
-			if j == 0 {
-				fmt.Fprintf(w, "- **[KEEP]** %s\n", file.path)
-			} else {
-				fmt.Fprintf(w, "- %s\n", file.path)
-			}
-		}
-		fmt.Fprintf(w, "\n")
+	if err := report.WriteMarkdown(reportFile); err != nil {
+		log.Fatal("Error writing duplicates report:", err)
 	}
 
-	fmt.Printf("\n✅ Duplicates report generated: gorder_dups.md\n")
-	fmt.Printf("   Duplicate groups: %d\n", len(duplicateGroups))
+	duplicateCount := report.Count()
+	fmt.Printf("\n✅ Duplicates report generated: %s\n", organizer.DupsReportName)
+	fmt.Printf("   Duplicate groups: %d\n", len(report.Groups))
 	fmt.Printf("   Duplicate files: %d\n", duplicateCount)
-	fmt.Printf("   Wasted space: %s\n", formatSize(duplicateSize))
+	fmt.Printf("   Files read in full: %d of %d (%s)\n", report.Hashed, report.Scanned, report.Algorithm)
+	if report.Cached > 0 {
+		fmt.Printf("   Files not read, hashes cached: %d\n", report.Cached)
+	}
+	fmt.Printf("   Wasted space: %s\n", organizer.FormatSize(report.WastedSize()))
 
 	// Handle deletion if requested
 	if deleteDups {
 		fmt.Printf("\n⚠️  Delete duplicates mode enabled!\n")
-		fmt.Printf("   This will delete %d duplicate files (keeping first instance of each).\n", duplicateCount)
+		fmt.Printf("   This will delete %d duplicate files (keeping the copy marked KEEP in each group).\n", duplicateCount)
 		fmt.Printf("   Type 'yes' to confirm deletion: ")
 
 		var response string
 		fmt.Scanln(&response)
 
-		if response == "yes" {
-			deletedCount := 0
-			deletedSize := int64(0)
-
-			for _, group := range duplicateGroups {
-				// Keep first file, delete the rest
-				for i := 1; i < len(group); i++ {
-					if err := os.Remove(group[i].path); err != nil {
-						log.Printf("Error deleting %s: %v\n", group[i].path, err)
-					} else {
-						fmt.Printf("Deleted: %s\n", group[i].path)
-						deletedCount++
-						deletedSize += group[i].size
-					}
-				}
-			}
-
-			fmt.Printf("\n✅ Deletion complete!\n")
-			fmt.Printf("   Files deleted: %d\n", deletedCount)
-			fmt.Printf("   Space freed: %s\n", formatSize(deletedSize))
-		} else {
+		if response != "yes" {
 			fmt.Println("Deletion cancelled.")
+			return
 		}
-	}
-}
 
-func hashFile(path string) (string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
+		deleted, errs := report.Delete()
+		deletedSize := int64(0)
+		for _, file := range deleted {
+			fmt.Printf("Deleted: %s\n", file.Path)
+			deletedSize += file.Size
+		}
+		for _, err := range errs {
+			log.Println(err)
+		}
 
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", err
+		fmt.Printf("\n✅ Deletion complete!\n")
+		fmt.Printf("   Files deleted: %d\n", len(deleted))
+		fmt.Printf("   Space freed: %s\n", organizer.FormatSize(deletedSize))
 	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
 }
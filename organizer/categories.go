@@ -0,0 +1,32 @@
+package organizer
+
+// CategoryMap defines grouping of extensions into categories. An
+// extension may only belong to one category.
+var CategoryMap = map[string][]string{
+	"Images":        {"jpg", "jpeg", "png", "gif", "webp", "bmp", "tiff", "tif", "svg", "heic", "heif", "ico", "raw", "cr2", "nef", "orf", "arw", "dds", "hdr", "jp2"},
+	"Videos":        {"mp4", "mov", "avi", "mkv", "wmv", "flv", "mpeg", "mpg", "m4v", "3gp", "webm", "vob", "m2ts", "rm", "rmvb", "asf"},
+	"Audio":         {"mp3", "wav", "aac", "flac", "ogg", "m4a", "wma", "alac", "aiff", "amr", "mid", "midi", "opus", "pcm"},
+	"Documents":     {"doc", "docx", "pdf", "txt", "rtf", "odt", "md", "epub", "tex", "ps", "pages", "djvu", "fodt", "rtfd"},
+	"Spreadsheets":  {"xls", "xlsx", "csv", "ods", "tsv", "xlsm", "xlsb", "numbers"},
+	"Presentations": {"ppt", "pptx", "odp", "key", "pps", "ppsx"},
+	"Archives":      {"zip", "tar", "tar.gz", "tgz", "rar", "7z", "xz", "iso", "bz2", "gz", "lz", "lzma", "cab", "zst", "arj"},
+	"Executables":   {"exe", "msi", "bat", "cmd", "apk", "aab", "ipa", "dmg", "pkg", "app", "deb", "rpm", "flatpak", "snap", "jar", "war", "bin", "sh"},
+	"Web":           {"html", "htm", "css", "js", "ts", "jsx", "tsx"},
+	"Data":          {"json", "xml", "yaml", "yml", "ini", "toml", "ndjson"},
+	"Code":          {"c", "h", "cpp", "hpp", "cs", "java", "kt", "py", "rb", "php", "go", "rs", "swift", "scala", "lua", "pl", "ps1", "sql", "r", "m", "asm", "dart"},
+	"Design":        {"psd", "psb", "ai", "eps", "indd", "xd", "fig", "sketch", "cdr", "afdesign", "afphoto", "afpub"},
+	"Fonts":         {"ttf", "otf", "woff", "woff2", "eot", "fon", "pfb", "pfa"},
+	"3D":            {"blend", "fbx", "obj", "stl", "3ds", "dae", "ply", "glb", "gltf", "max", "usd", "usdz"},
+	"CAD":           {"dwg", "dxf", "dwt", "stp", "step", "iges", "igs", "sldprt", "sldasm", "ipt", "iam"},
+	"Config":        {"log", "cfg", "conf", "env", "editorconfig", "properties", "jsonc", "reg"},
+	"Database":      {"db", "sqlite", "sqlite3", "mdb", "accdb", "dbf", "parquet", "feather", "hdf5", "h5"},
+	"Backup":        {"bak", "tmp", "old", "backup", "swp", "swo"},
+}
+
+// categoryMap returns the categories opts asks for
+func categoryMap(opts Options) map[string][]string {
+	if opts.CategoryMap != nil {
+		return opts.CategoryMap
+	}
+	return CategoryMap
+}
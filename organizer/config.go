@@ -0,0 +1,295 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configExts lists the supported config formats in lookup order
+var configExts = []string{".toml", ".yaml", ".yml", ".json"}
+
+// CategoryConfig edits a single category. Extensions replaces the
+// category's extensions (creating the category if needed), Add and
+// Remove edit them, and Delete drops the category altogether.
+type CategoryConfig struct {
+	Extensions []string `toml:"extensions" yaml:"extensions" json:"extensions"`
+	Add        []string `toml:"add" yaml:"add" json:"add"`
+	Remove     []string `toml:"remove" yaml:"remove" json:"remove"`
+	Delete     bool     `toml:"delete" yaml:"delete" json:"delete"`
+}
+
+// fileConfig is the content of a single config file
+type fileConfig struct {
+	// BuiltinCategories set to false starts from no categories instead of
+	// the built-in ones
+	BuiltinCategories *bool                     `toml:"builtin_categories" yaml:"builtin_categories" json:"builtin_categories"`
+	Categories        map[string]CategoryConfig `toml:"categories" yaml:"categories" json:"categories"`
+	Rules             []Rule                    `toml:"rules" yaml:"rules" json:"rules"`
+	// DatePatterns find dates in file names, see Options.DatePatterns
+	DatePatterns []string `toml:"date_patterns" yaml:"date_patterns" json:"date_patterns"`
+}
+
+// Config is the configuration merged from one or more config files.
+// Later files are applied on top of earlier ones.
+type Config struct {
+	// Files lists the files the config was loaded from, in order
+	Files  []string
+	layers []*fileConfig
+}
+
+// ConfigPaths returns the config files that apply to dir: the user
+// config in $XDG_CONFIG_HOME/gorder followed by the project config
+// (.gorder.toml, .gorder.yaml or .gorder.json) in dir. It fails if a
+// location holds more than one config file.
+func ConfigPaths(dir string) ([]string, error) {
+	var paths []string
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome, _ = os.UserConfigDir()
+	}
+	if configHome != "" {
+		path, err := findConfig(filepath.Join(configHome, "gorder", "config"))
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	path, err := findConfig(filepath.Join(dir, ".gorder"))
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// findConfig returns the config file with the given base path and one of
+// the supported extensions
+func findConfig(base string) (string, error) {
+	var found []string
+	for _, ext := range configExts {
+		if _, err := os.Stat(base + ext); err == nil {
+			found = append(found, base+ext)
+		}
+	}
+	if len(found) > 1 {
+		return "", fmt.Errorf("conflicting config files: %s", strings.Join(found, ", "))
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	return found[0], nil
+}
+
+// LoadConfig reads and merges the given config files. The format of each
+// file is chosen by its extension.
+func LoadConfig(paths ...string) (*Config, error) {
+	c := &Config{}
+	for _, path := range paths {
+		layer, err := readConfigFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+		c.Files = append(c.Files, path)
+		c.layers = append(c.layers, layer)
+	}
+	categories, err := c.CategoryMap()
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range c.Rules() {
+		if err := rule.compile(Options{CategoryMap: categories}); err != nil {
+			return nil, fmt.Errorf("%s: %w", rule.label(i), err)
+		}
+	}
+	if _, err := compileDatePatterns(c.DatePatterns()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Rules returns the routing rules of every config file. Rules of later
+// (more specific) files come first so they take precedence.
+func (c *Config) Rules() []Rule {
+	var rules []Rule
+	if c == nil {
+		return rules
+	}
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		rules = append(rules, c.layers[i].Rules...)
+	}
+	return rules
+}
+
+// DatePatterns returns the file name date patterns of every config file,
+// those of later (more specific) files first
+func (c *Config) DatePatterns() []string {
+	var patterns []string
+	if c == nil {
+		return patterns
+	}
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		patterns = append(patterns, c.layers[i].DatePatterns...)
+	}
+	return patterns
+}
+
+func readConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := &fileConfig{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		md, err := toml.Decode(string(data), layer)
+		if err != nil {
+			return nil, err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("unknown setting %q", undecoded[0].String())
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(layer); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(layer); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", filepath.Ext(path))
+	}
+
+	for name := range layer.Categories {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("category with an empty name")
+		}
+	}
+	return layer, nil
+}
+
+// CategoryMap returns the categories after applying every config file to
+// the built-in CategoryMap. It fails if an extension ends up in more than
+// one category.
+func (c *Config) CategoryMap() (map[string][]string, error) {
+	categories := copyCategories(CategoryMap)
+	if c != nil {
+		for _, layer := range c.layers {
+			if layer.BuiltinCategories != nil && !*layer.BuiltinCategories {
+				categories = make(map[string][]string)
+			}
+			for name, edit := range layer.Categories {
+				applyCategoryConfig(categories, name, edit)
+			}
+		}
+	}
+	if _, err := CategoryIndex(categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func applyCategoryConfig(categories map[string][]string, name string, edit CategoryConfig) {
+	if edit.Delete {
+		delete(categories, name)
+		return
+	}
+
+	exts := categories[name]
+	if edit.Extensions != nil {
+		exts = nil
+		for _, ext := range edit.Extensions {
+			exts = appendExt(exts, ext)
+		}
+	}
+	for _, ext := range edit.Add {
+		exts = appendExt(exts, ext)
+	}
+	for _, ext := range edit.Remove {
+		ext = normalizeExt(ext)
+		for i, e := range exts {
+			if e == ext {
+				exts = append(exts[:i:i], exts[i+1:]...)
+				break
+			}
+		}
+	}
+	categories[name] = exts
+}
+
+// appendExt adds ext to exts unless it is already there
+func appendExt(exts []string, ext string) []string {
+	ext = normalizeExt(ext)
+	if ext == "" {
+		return exts
+	}
+	for _, e := range exts {
+		if e == ext {
+			return exts
+		}
+	}
+	return append(exts, ext)
+}
+
+// normalizeExt lower-cases an extension and strips its leading dot
+func normalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+func copyCategories(categories map[string][]string) map[string][]string {
+	c := make(map[string][]string, len(categories))
+	for name, exts := range categories {
+		c[name] = append([]string(nil), exts...)
+	}
+	return c
+}
+
+// CategoryIndex builds the extension to category lookup table for
+// categories. Extensions listed in more than one category are reported
+// as an error rather than resolved arbitrarily.
+func CategoryIndex(categories map[string][]string) (map[string]string, error) {
+	owners := make(map[string][]string)
+	for category, exts := range categories {
+		for _, ext := range exts {
+			ext = normalizeExt(ext)
+			owners[ext] = append(owners[ext], category)
+		}
+	}
+
+	extToCat := make(map[string]string, len(owners))
+	var conflicts []string
+	for ext, cats := range owners {
+		if len(cats) > 1 {
+			sort.Strings(cats)
+			conflicts = append(conflicts, fmt.Sprintf(".%s is in %s", ext, strings.Join(cats, ", ")))
+			continue
+		}
+		extToCat[ext] = cats[0]
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("extensions mapped to more than one category: %s", strings.Join(conflicts, "; "))
+	}
+	return extToCat, nil
+}
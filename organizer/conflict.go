@@ -0,0 +1,141 @@
+package organizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Conflict policies decide what happens when a file is about to be put
+// where another file already is
+const (
+	// ConflictSkip leaves both files alone
+	ConflictSkip = "skip"
+	// ConflictRename puts the file next to the other one as "name (N).ext"
+	ConflictRename = "rename"
+	// ConflictOverwrite replaces the other file
+	ConflictOverwrite = "overwrite"
+	// ConflictPrompt asks which of the above to apply
+	ConflictPrompt = "prompt"
+	// ConflictKeepNewer keeps whichever file was modified last
+	ConflictKeepNewer = "keep-newer"
+	// ConflictKeepLarger keeps whichever file is larger
+	ConflictKeepLarger = "keep-larger"
+	// ConflictDedupe drops the file if the other one has the same content,
+	// and renames it otherwise
+	ConflictDedupe = "dedupe"
+)
+
+// checkConflictPolicy fails if policy is not one of the policies in valid
+func checkConflictPolicy(policy string, valid ...string) error {
+	for _, v := range valid {
+		if policy == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown conflict policy %q", policy)
+}
+
+// checkPlanPolicy fails if policy cannot be used when planning moves
+func checkPlanPolicy(policy string) error {
+	if policy == "" {
+		return nil
+	}
+	return checkConflictPolicy(policy, ConflictRename, ConflictSkip, ConflictOverwrite,
+		ConflictKeepNewer, ConflictKeepLarger, ConflictDedupe)
+}
+
+// resolveConflict applies the planner's conflict policy to move when its
+// destination is taken, by a file on disk or by an earlier planned move.
+// A file replaced on disk is planned to go to the trash first, so undo
+// can bring it back; a planned move that is replaced is dropped, leaving
+// its source in place. It returns false if move is left out of the plan.
+func (p *planner) resolveConflict(move *Move, info os.FileInfo) bool {
+	if move.Op == OpTrash || p.onConflict == "" || p.onConflict == ConflictRename {
+		return true
+	}
+
+	// other is the file that would be replaced
+	otherPath := move.Dest
+	i, planned := p.planned[move.Dest]
+	if planned {
+		otherPath = p.plan.Moves[i].Source
+	} else if taken, ok := p.taken[move.Dest]; ok && !taken {
+		return true
+	}
+	other, err := os.Lstat(otherPath)
+	if err != nil {
+		return true
+	}
+	if !other.Mode().IsRegular() || !info.Mode().IsRegular() {
+		return true
+	}
+	if os.SameFile(info, other) {
+		p.skip(move.Source, "already at "+move.Dest)
+		return false
+	}
+
+	var replace bool
+	switch p.onConflict {
+	case ConflictSkip:
+	case ConflictOverwrite:
+		replace = true
+	case ConflictKeepNewer:
+		replace = info.ModTime().After(other.ModTime())
+	case ConflictKeepLarger:
+		replace = info.Size() > other.Size()
+	case ConflictDedupe:
+		same, err := sameContent(move.Source, otherPath)
+		if err != nil {
+			p.skip(move.Source, err.Error())
+			return false
+		}
+		if !same {
+			return true
+		}
+		return p.dropDuplicate(move, otherPath)
+	}
+
+	if !replace {
+		p.skip(move.Source, fmt.Sprintf("%s already exists (%s)", move.Dest, p.onConflict))
+		return false
+	}
+	if planned {
+		p.skip(otherPath, fmt.Sprintf("replaced by %s at %s under %s", move.Source, move.Dest, p.onConflict))
+		p.drop(i)
+		return true
+	}
+
+	dir, err := trashFilesDir()
+	if err != nil {
+		p.skip(move.Source, fmt.Sprintf("cannot replace %s: %v", move.Dest, err))
+		return false
+	}
+	p.place(Move{
+		Source: move.Dest,
+		Dest:   filepath.Join(dir, filepath.Base(move.Dest)),
+		Op:     OpTrash,
+		Reason: fmt.Sprintf("replaced by %s under %s", move.Source, p.onConflict),
+	}, other)
+	p.taken[move.Dest] = false
+	return true
+}
+
+// dropDuplicate plans what happens to move's source when the file at
+// original has the same content: moved files go to the trash, while
+// copies and links are simply not made
+func (p *planner) dropDuplicate(move *Move, original string) bool {
+	if move.Op != "" && move.Op != OpMove {
+		p.skip(move.Source, "identical to "+original)
+		return false
+	}
+	dir, err := trashFilesDir()
+	if err != nil {
+		p.skip(move.Source, fmt.Sprintf("identical to %s, but %v", original, err))
+		return false
+	}
+	move.Op = OpTrash
+	move.Dest = filepath.Join(dir, filepath.Base(move.Source))
+	move.Reason = "identical to " + original
+	return true
+}
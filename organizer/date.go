@@ -0,0 +1,331 @@
+package organizer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date sources a file's date can be read from
+const (
+	// DateSourceAuto tries EXIF, then video and audio metadata, then the
+	// file name, then the modification time
+	DateSourceAuto = "auto"
+	// DateSourceExif reads the date a photo was taken from its EXIF metadata
+	DateSourceExif = "exif"
+	// DateSourceMedia reads the date a video or audio recording was made
+	// from its MP4, QuickTime or Matroska metadata
+	DateSourceMedia = "media"
+	// DateSourceMtime uses the modification time
+	DateSourceMtime = "mtime"
+	// DateSourceCtime uses the time the file's metadata last changed
+	DateSourceCtime = "ctime"
+	// DateSourceBirth uses the creation time, where the filesystem
+	// records it
+	DateSourceBirth = "birth"
+	// DateSourceFilename parses a date such as 20240514_153012 from the
+	// file name, see Options.DatePatterns
+	DateSourceFilename = "filename"
+)
+
+// dateChains lists the sources tried in order for each date source. Every
+// chain ends with the modification time, which is always known.
+var dateChains = map[string][]string{
+	DateSourceAuto:     {DateSourceExif, DateSourceMedia, DateSourceFilename, DateSourceMtime},
+	DateSourceExif:     {DateSourceExif, DateSourceMtime},
+	DateSourceMedia:    {DateSourceMedia, DateSourceMtime},
+	DateSourceMtime:    {DateSourceMtime},
+	DateSourceCtime:    {DateSourceCtime, DateSourceMtime},
+	DateSourceBirth:    {DateSourceBirth, DateSourceMtime},
+	DateSourceFilename: {DateSourceFilename, DateSourceMtime},
+}
+
+// checkDateSource fails if source is not one of the date sources
+func checkDateSource(source string) error {
+	if _, ok := dateChains[source]; !ok && source != "" {
+		return fmt.Errorf("unknown date source %q (want auto, exif, media, mtime, ctime, birth or filename)", source)
+	}
+	return nil
+}
+
+// Date returns the date of the file according to source, one of the
+// DateSource constants (DateSourceAuto when empty), and the source it was
+// actually taken from. When source has no date for the file, the next
+// source of its fallback chain is tried, down to the modification time.
+func (e Entry) Date(source string) (time.Time, string) {
+	if source == "" {
+		source = DateSourceAuto
+	}
+	for _, s := range dateChains[source] {
+		if t, ok := e.dateFrom(s); ok {
+			return t, s
+		}
+	}
+	return e.inZone(e.Info.ModTime()), DateSourceMtime
+}
+
+// dateSettings tell how dates are read from files
+type dateSettings struct {
+	// patterns are tried before the built-in ones to find a date in the
+	// file name
+	patterns []*regexp.Regexp
+	// loc is the zone dates are converted to and wall clock times are
+	// read in, see Options.TimeZone; nil for local time
+	loc *time.Location
+}
+
+// location returns the zone wall clock times of the entry are read in
+func (e Entry) location() *time.Location {
+	if e.dateSettings == nil || e.dateSettings.loc == nil {
+		return time.Local
+	}
+	return e.dateSettings.loc
+}
+
+// inZone converts t to the configured time zone, if any
+func (e Entry) inZone(t time.Time) time.Time {
+	if e.dateSettings == nil || e.dateSettings.loc == nil {
+		return t
+	}
+	return t.In(e.dateSettings.loc)
+}
+
+// dateFrom reads the date of the file from a single source, caching it
+// in the entry
+func (e Entry) dateFrom(source string) (time.Time, bool) {
+	if d, ok := e.dates[source]; ok {
+		return d.time, d.ok
+	}
+
+	var t time.Time
+	ok := false
+	switch source {
+	case DateSourceMtime:
+		t, ok = e.Info.ModTime(), true
+	case DateSourceCtime:
+		t, ok = changeTime(e.Path, e.Info)
+	case DateSourceBirth:
+		t, ok = birthTime(e.Path, e.Info)
+	case DateSourceExif:
+		if e.Info.Mode().IsRegular() && exifExts[getExtension(e.Name(), false)] {
+			t, ok = exifDate(e.Path, e.location())
+		}
+	case DateSourceMedia:
+		if e.Info.Mode().IsRegular() && mediaExts[getExtension(e.Name(), false)] {
+			t, ok = mediaDate(e.Path)
+		}
+	case DateSourceFilename:
+		var patterns []*regexp.Regexp
+		if e.dateSettings != nil {
+			patterns = e.dateSettings.patterns
+		}
+		t, ok = filenameDate(e.Name(), patterns, e.location())
+	}
+	if ok {
+		t = e.inZone(t)
+	}
+	if e.dates != nil {
+		e.dates[source] = entryDate{t, ok}
+	}
+	return t, ok
+}
+
+// entryDate is a date read from one source, or its absence
+type entryDate struct {
+	time time.Time
+	ok   bool
+}
+
+// Date modes name the period a date folder spans. Modes can be nested
+// with slashes, such as "year/month".
+const (
+	DateModeYear       = "year"
+	DateModeQuarter    = "quarter"
+	DateModeMonth      = "month"
+	DateModeWeek       = "week"
+	DateModeDay        = "day"
+	DateModeFiscalYear = "fiscal-year"
+	DateModeDecade     = "decade"
+)
+
+var dateModes = []string{DateModeYear, DateModeQuarter, DateModeMonth, DateModeWeek,
+	DateModeDay, DateModeFiscalYear, DateModeDecade}
+
+// checkDateMode fails if mode is not a date mode or several nested with
+// slashes
+func checkDateMode(mode string) error {
+	for _, m := range strings.Split(mode, "/") {
+		if !slices.Contains(dateModes, m) {
+			return fmt.Errorf("unknown date mode %q (want %s, or several nested such as year/month)",
+				m, strings.Join(dateModes, ", "))
+		}
+	}
+	return nil
+}
+
+// getDateFolder returns the folder of t under mode, with one level per
+// nested mode. fiscalStart is the first month of the fiscal year; fiscal
+// years are named after the calendar year they end in. It returns false
+// for an unknown mode.
+func getDateFolder(t time.Time, mode string, fiscalStart time.Month) (string, bool) {
+	var parts []string
+	for _, m := range strings.Split(mode, "/") {
+		var part string
+		switch m {
+		case DateModeYear:
+			part = fmt.Sprintf("%04d", t.Year())
+		case DateModeQuarter:
+			part = fmt.Sprintf("%04d-Q%d", t.Year(), quarter(t))
+		case DateModeMonth:
+			part = fmt.Sprintf("%04d-%02d", t.Year(), t.Month())
+		case DateModeWeek:
+			// The ISO year differs from the calendar year around New Year
+			year, week := t.ISOWeek()
+			part = fmt.Sprintf("%04d-W%02d", year, week)
+		case DateModeDay:
+			part = fmt.Sprintf("%04d-%02d-%02d", t.Year(), t.Month(), t.Day())
+		case DateModeFiscalYear:
+			part = fmt.Sprintf("FY%04d", fiscalYear(t, fiscalStart))
+		case DateModeDecade:
+			part = fmt.Sprintf("%04ds", t.Year()/10*10)
+		default:
+			return "", false
+		}
+		parts = append(parts, part)
+	}
+	return filepath.Join(parts...), true
+}
+
+// quarter returns the calendar quarter of t, 1 to 4
+func quarter(t time.Time) int {
+	return (int(t.Month())-1)/3 + 1
+}
+
+// fiscalYear returns the fiscal year t falls in when fiscal years start
+// in month start, named after the calendar year the fiscal year ends in
+func fiscalYear(t time.Time, start time.Month) int {
+	if start > time.January && t.Month() >= start {
+		return t.Year() + 1
+	}
+	return t.Year()
+}
+
+// ParseMonth parses a month given as a number from 1 to 12 or as an
+// English name such as "april" or "Apr"
+func ParseMonth(s string) (time.Month, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 || n > 12 {
+			return 0, fmt.Errorf("invalid month %q", s)
+		}
+		return time.Month(n), nil
+	}
+	for m := time.January; m <= time.December; m++ {
+		name := m.String()
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid month %q", s)
+}
+
+// checkDateFormat fails if format, a Go layout such as "2006/01" or a
+// strftime format such as "%Y/%m", holds no date fields or renders path
+// segments that cannot be folder names
+func checkDateFormat(format string) error {
+	ref := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	out := formatDate(ref, format)
+	if !strings.Contains(format, "%") && out == format {
+		return fmt.Errorf("date format %q has no date fields (use a Go layout such as 2006/01 or strftime such as %%Y/%%m)", format)
+	}
+	if strings.HasPrefix(out, "/") {
+		return fmt.Errorf("date format %q must be relative", format)
+	}
+	for _, segment := range strings.Split(out, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("date format %q has an empty, \".\" or \"..\" path segment", format)
+		}
+	}
+	return nil
+}
+
+// formatDate renders t with format, a strftime format if it contains a
+// '%' and a Go layout otherwise. Slashes in the result separate nested
+// folders.
+func formatDate(t time.Time, format string) string {
+	if !strings.Contains(format, "%") {
+		return t.Format(format)
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'C':
+			fmt.Fprintf(&b, "%02d", t.Year()/100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", t.Month())
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&b, "%02d", (t.Hour()+11)%12+1)
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'u':
+			fmt.Fprintf(&b, "%d", (int(t.Weekday())+6)%7+1)
+		case 'w':
+			fmt.Fprintf(&b, "%d", t.Weekday())
+		case 'G':
+			year, _ := t.ISOWeek()
+			fmt.Fprintf(&b, "%04d", year)
+		case 'V':
+			_, week := t.ISOWeek()
+			fmt.Fprintf(&b, "%02d", week)
+		case 'q':
+			// Not in C strftime, but common in date libraries
+			fmt.Fprintf(&b, "%d", quarter(t))
+		case 'F':
+			fmt.Fprintf(&b, "%04d-%02d-%02d", t.Year(), t.Month(), t.Day())
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
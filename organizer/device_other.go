@@ -0,0 +1,9 @@
+//go:build !linux
+
+package organizer
+
+// isRotational reports false where gorder cannot tell spinning disks
+// apart; set DuplicateOptions.DeviceJobs to limit them
+func isRotational(dev uint64) bool {
+	return false
+}
@@ -0,0 +1,490 @@
+package organizer
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DupsReportName is the file the duplicates report is conventionally
+// written to
+const DupsReportName = "gorder_dups.md"
+
+// DuplicateFile is one copy of a duplicated file
+type DuplicateFile struct {
+	Path string
+	Size int64
+
+	// dev and ino are the device the file is on and its inode number, zero
+	// if unknown
+	dev, ino uint64
+	// mtime is the modification time in nanoseconds since the epoch
+	mtime int64
+}
+
+// duplicateFile describes the file at path
+func duplicateFile(path string, info os.FileInfo) DuplicateFile {
+	dev, ino, _ := fileID(info)
+	return DuplicateFile{
+		Path:  path,
+		Size:  info.Size(),
+		dev:   dev,
+		ino:   ino,
+		mtime: info.ModTime().UnixNano(),
+	}
+}
+
+// DuplicateReport lists the groups of identical files below a directory
+type DuplicateReport struct {
+	Scanned     int
+	ScannedSize int64
+	// Algorithm names the hash algorithm files were compared with; hashes
+	// of different algorithms cannot be compared
+	Algorithm string
+	// Hashed is the number of files whose whole content was read to hash
+	// them, whether by the sample of a small file or in full
+	Hashed int
+	// Cached is the number of files that were not read at all because all
+	// their hashes came from the hash cache
+	Cached int
+	// Groups is sorted by file size, largest first. The first file of
+	// each group is the copy that is kept, see DuplicateOptions.Keep.
+	Groups [][]DuplicateFile
+	// Errors holds files that could not be hashed
+	Errors []error
+}
+
+// DuplicateOptions configures FindDuplicates
+type DuplicateOptions struct {
+	// ExcludeDirs prunes matching directories, see Options.ExcludeDirs
+	ExcludeDirs []string
+	// Verify compares the files of each group byte by byte after hashing,
+	// ruling out hash collisions
+	Verify bool
+	// Jobs is the number of files hashed at once, runtime.NumCPU() when
+	// zero
+	Jobs int
+	// DeviceJobs limits the files hashed at once on a single device. When
+	// zero, spinning disks are read one file at a time (detected on Linux)
+	// and other devices up to Jobs at a time.
+	DeviceJobs int
+	// Hasher is the hash algorithm files are compared with, SHA-256 when
+	// nil
+	Hasher Hasher
+	// Cache holds the hashes of earlier runs and receives the new ones.
+	// FindDuplicates does not save it. No cache is used when nil.
+	Cache *HashCache
+	// Keep is the policy choosing the copy of each group that is kept,
+	// KeepFirst when empty
+	Keep string
+	// KeepIn lists directories whose copies are kept over any other, the
+	// first one listed taking precedence. Relative directories are below
+	// the scanned one.
+	KeepIn []string
+	// PreferDelete lists directories whose copies are only kept when all
+	// copies are in one of them
+	PreferDelete []string
+}
+
+// sampleSize is the number of bytes read from each end of a file to
+// tell apart files of the same size before hashing them in full
+const sampleSize = 16 << 10
+
+// FindDuplicates groups the regular, non-hidden files below dir that
+// have identical content. Only files sharing their size with another file
+// are read: first a sample of their head and tail, then, for those whose
+// samples match, their whole content.
+func FindDuplicates(ctx context.Context, dir string, opts DuplicateOptions) (*DuplicateReport, error) {
+	keep, err := newKeeper(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &DuplicateReport{}
+	bySize := make(map[int64][]DuplicateFile)
+	var sizes []int64
+
+	err = walkTree(ctx, dir, opts.ExcludeDirs, func(path string, info os.FileInfo) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		// Skip hidden files
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			return nil
+		}
+
+		// Skip report files
+		if filepath.Base(path) == ReportName || filepath.Base(path) == DupsReportName {
+			return nil
+		}
+
+		r.Scanned++
+		r.ScannedSize += info.Size()
+
+		if _, ok := bySize[info.Size()]; !ok {
+			sizes = append(sizes, info.Size())
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], duplicateFile(path, info))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning for duplicates: %w", err)
+	}
+
+	// A file with a unique size cannot have a duplicate
+	var candidates [][]DuplicateFile
+	for _, size := range sizes {
+		if len(bySize[size]) > 1 {
+			candidates = append(candidates, bySize[size])
+		}
+	}
+
+	hasher := opts.Hasher
+	if hasher == nil {
+		hasher, _ = NewHasher(HashSHA256)
+	}
+	r.Algorithm = hasher.Name()
+	pool := newHashPool(opts.Jobs, opts.DeviceJobs)
+	tally := newHashTally()
+	groups, err := r.split(ctx, pool, candidates,
+		cachedHash(opts.Cache, hasher.Name(), hashKindSample, sampleHash(hasher), tally))
+	if err != nil {
+		return nil, err
+	}
+	// The sample of a small file is all of it
+	var large [][]DuplicateFile
+	for _, group := range groups {
+		if group[0].Size > 2*sampleSize {
+			large = append(large, group)
+		} else {
+			r.Groups = append(r.Groups, group)
+		}
+	}
+	large, err = r.split(ctx, pool, large,
+		cachedHash(opts.Cache, hasher.Name(), hashKindFull, fileHash(hasher), tally))
+	if err != nil {
+		return nil, err
+	}
+	r.Groups = append(r.Groups, large...)
+	r.Hashed, r.Cached = tally.counts()
+
+	if opts.Verify {
+		if r.Groups, err = r.verify(ctx, r.Groups); err != nil {
+			return nil, err
+		}
+	}
+
+	keep.order(r.Groups)
+
+	// Sort groups by size (largest first), keeping the order files were
+	// found in otherwise
+	sort.SliceStable(r.Groups, func(i, j int) bool {
+		return r.Groups[i][0].Size > r.Groups[j][0].Size
+	})
+
+	return r, nil
+}
+
+// split divides groups by the hash of their files, keeping the files
+// that share their hash with another. Files that cannot be hashed are
+// reported in r.Errors and left out. Groups and the files in them keep
+// their order.
+func (r *DuplicateReport) split(ctx context.Context, pool *hashPool, groups [][]DuplicateFile, hash func(DuplicateFile) (string, error)) ([][]DuplicateFile, error) {
+	var files []DuplicateFile
+	for _, group := range groups {
+		files = append(files, group...)
+	}
+	hashes, errs, err := pool.hashAll(ctx, files, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var result [][]DuplicateFile
+	i := 0
+	for _, group := range groups {
+		byHash := make(map[string][]DuplicateFile)
+		var order []string
+		for _, file := range group {
+			h, err := hashes[i], errs[i]
+			i++
+			if err != nil {
+				r.Errors = append(r.Errors, fmt.Errorf("error hashing %s: %w", file.Path, err))
+				continue
+			}
+			if _, ok := byHash[h]; !ok {
+				order = append(order, h)
+			}
+			byHash[h] = append(byHash[h], file)
+		}
+		for _, h := range order {
+			if len(byHash[h]) > 1 {
+				result = append(result, byHash[h])
+			}
+		}
+	}
+	return result, nil
+}
+
+// verify compares the files of each group byte by byte with the
+// group's first file, splitting off any that differ despite their equal
+// hashes
+func (r *DuplicateReport) verify(ctx context.Context, groups [][]DuplicateFile) ([][]DuplicateFile, error) {
+	var result [][]DuplicateFile
+	for len(groups) > 0 {
+		files := groups[0]
+		groups = groups[1:]
+
+		same := files[:1:1]
+		var rest []DuplicateFile
+		for _, file := range files[1:] {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			ok, err := sameContent(files[0].Path, file.Path)
+			switch {
+			case err != nil:
+				r.Errors = append(r.Errors, fmt.Errorf("error comparing %s: %w", file.Path, err))
+			case ok:
+				same = append(same, file)
+			default:
+				rest = append(rest, file)
+			}
+		}
+		if len(same) > 1 {
+			result = append(result, same)
+		}
+		if len(rest) > 1 {
+			groups = append(groups, rest)
+		}
+	}
+	return result, nil
+}
+
+// Count returns the number of redundant copies, not counting the kept ones
+func (r *DuplicateReport) Count() int {
+	n := 0
+	for _, group := range r.Groups {
+		n += len(group) - 1
+	}
+	return n
+}
+
+// WastedSize returns the space taken by the redundant copies
+func (r *DuplicateReport) WastedSize() int64 {
+	var size int64
+	for _, group := range r.Groups {
+		for _, file := range group[1:] {
+			size += file.Size
+		}
+	}
+	return size
+}
+
+// WriteMarkdown renders the report as Markdown
+func (r *DuplicateReport) WriteMarkdown(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	// Header
+	fmt.Fprintf(w, "# Gorder Duplicate Files Report\n\n")
+	fmt.Fprintf(w, "Generated: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "---\n\n")
+
+	// Summary
+	fmt.Fprintf(w, "## 📊 Summary\n\n")
+	fmt.Fprintf(w, "- **Total Files Scanned**: %d\n", r.Scanned)
+	fmt.Fprintf(w, "- **Duplicate Groups**: %d\n", len(r.Groups))
+	fmt.Fprintf(w, "- **Duplicate Files**: %d\n", r.Count())
+	fmt.Fprintf(w, "- **Files Read in Full**: %d\n", r.Hashed)
+	if r.Cached > 0 {
+		fmt.Fprintf(w, "- **Files Not Read (Cached Hashes)**: %d\n", r.Cached)
+	}
+	fmt.Fprintf(w, "- **Hash Algorithm**: %s\n", r.Algorithm)
+	fmt.Fprintf(w, "- **Wasted Space**: %s\n\n", FormatSize(r.WastedSize()))
+
+	// Duplicate Groups
+	fmt.Fprintf(w, "## 🔍 Duplicate Groups\n\n")
+
+	for i, group := range r.Groups {
+		fmt.Fprintf(w, "### Group %d (Size: %s, %d copies)\n\n", i+1, FormatSize(group[0].Size), len(group))
+		for j, file := range group {
+			if j == 0 {
+				fmt.Fprintf(w, "- **[KEEP]** %s\n", file.Path)
+			} else {
+				fmt.Fprintf(w, "- %s\n", file.Path)
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	return w.Flush()
+}
+
+// Delete removes every copy but the first of each group. It returns the
+// files it deleted and the errors of those it could not. A group is left
+// alone unless its first copy is still there unchanged, so that at least
+// one copy always survives, and each other copy is compared with it byte
+// by byte right before it is deleted, so hash collisions and stale cached
+// hashes never cost a file.
+func (r *DuplicateReport) Delete() ([]DuplicateFile, []error) {
+	var deleted []DuplicateFile
+	var errs []error
+
+	for _, group := range r.Groups {
+		kept, err := os.Lstat(group[0].Path)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("keeping %s: %w; its copies are left alone", group[0].Path, err))
+			continue
+		case !kept.Mode().IsRegular() || kept.Size() != group[0].Size || kept.ModTime().UnixNano() != group[0].mtime:
+			errs = append(errs, fmt.Errorf("keeping %s: file changed since it was scanned; its copies are left alone", group[0].Path))
+			continue
+		}
+
+		// Keep first file, delete the rest once they are seen to still
+		// hold the same bytes, whatever hashes found them
+		for _, file := range group[1:] {
+			same, err := sameContent(group[0].Path, file.Path)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("error comparing %s with %s: %w; not deleted", file.Path, group[0].Path, err))
+				continue
+			case !same:
+				errs = append(errs, fmt.Errorf("%s differs from %s; not deleted", file.Path, group[0].Path))
+				continue
+			}
+			if err := os.Remove(file.Path); err != nil {
+				errs = append(errs, fmt.Errorf("error deleting %s: %w", file.Path, err))
+			} else {
+				deleted = append(deleted, file)
+			}
+		}
+	}
+	return deleted, errs
+}
+
+// hashTally records for each file whether its digests were computed or
+// taken from the hash cache
+type hashTally struct {
+	mu sync.Mutex
+	// read holds the files that a digest was computed for
+	read map[string]bool
+	// whole holds the files whose whole content was read
+	whole map[string]bool
+	// cached holds the files that a digest was taken from the cache for
+	cached map[string]bool
+}
+
+func newHashTally() *hashTally {
+	return &hashTally{
+		read:   make(map[string]bool),
+		whole:  make(map[string]bool),
+		cached: make(map[string]bool),
+	}
+}
+
+// record notes how a digest of the file at path was obtained
+func (t *hashTally) record(path string, cached, whole bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case cached:
+		t.cached[path] = true
+	case whole:
+		t.whole[path] = true
+		fallthrough
+	default:
+		t.read[path] = true
+	}
+}
+
+// counts returns the number of files read in full and the number of
+// files that were not read at all thanks to the cache
+func (t *hashTally) counts() (hashed, cached int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for path := range t.cached {
+		if !t.read[path] {
+			cached++
+		}
+	}
+	return len(t.whole), cached
+}
+
+// cachedHash wraps hash to take the digests of files that have not
+// changed since from cache and to record the others in it, noting in
+// tally how each digest was obtained. hash is used alone when cache is
+// nil.
+func cachedHash(cache *HashCache, algorithm, kind string, hash func(string) (string, error), tally *hashTally) func(DuplicateFile) (string, error) {
+	return func(file DuplicateFile) (string, error) {
+		// The sample of a small file is all of it
+		whole := kind == hashKindFull || file.Size <= 2*sampleSize
+		if cache != nil {
+			if digest, ok := cache.lookup(file, algorithm, kind); ok {
+				tally.record(file.Path, true, whole)
+				return digest, nil
+			}
+		}
+		digest, err := hash(file.Path)
+		if err != nil {
+			return "", err
+		}
+		tally.record(file.Path, false, whole)
+		if cache != nil {
+			cache.store(file, algorithm, kind, digest)
+		}
+		return digest, nil
+	}
+}
+
+// sampleHash returns a function hashing the first and last sampleSize
+// bytes of a file, or all of it if it is smaller, with h
+func sampleHash(h Hasher) func(string) (string, error) {
+	return func(path string) (string, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return "", err
+		}
+		hash := h.New()
+		if _, err := io.CopyN(hash, file, sampleSize); err != nil && err != io.EOF {
+			return "", err
+		}
+		if tail := max(info.Size()-sampleSize, sampleSize); tail < info.Size() {
+			if _, err := io.Copy(hash, io.NewSectionReader(file, tail, info.Size()-tail)); err != nil {
+				return "", err
+			}
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+}
+
+// fileHash returns a function hashing the whole content of a file with h
+func fileHash(h Hasher) func(string) (string, error) {
+	return func(path string) (string, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		hash := h.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+}
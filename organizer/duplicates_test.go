@@ -0,0 +1,87 @@
+package organizer
+
+import (
+	"context"
+	"hash"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// collidingHash gives every input the same digest, standing in for a
+// collision of a weak hash such as crc32c
+type collidingHash struct{}
+
+func (collidingHash) Write(p []byte) (int, error) { return len(p), nil }
+func (collidingHash) Sum(b []byte) []byte         { return append(b, 0x42) }
+func (collidingHash) Reset()                      {}
+func (collidingHash) Size() int                   { return 1 }
+func (collidingHash) BlockSize() int              { return 1 }
+
+type collidingHasher struct{}
+
+func (collidingHasher) Name() string   { return "colliding" }
+func (collidingHasher) New() hash.Hash { return collidingHash{} }
+
+func TestDeleteComparesCollidingCopies(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeFile(t, a, "aaaa")
+	writeFile(t, b, "bbbb")
+
+	opts := DuplicateOptions{Hasher: collidingHasher{}}
+	r, err := FindDuplicates(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Groups) != 1 {
+		t.Fatalf("got %d groups, want the 1 the collision makes", len(r.Groups))
+	}
+
+	deleted, errs := r.Delete()
+	if len(deleted) != 0 || len(errs) != 1 {
+		t.Errorf("Delete deleted %v with errors %v, want nothing deleted and one error", deleted, errs)
+	}
+	for _, path := range []string{a, b} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+
+	opts.Verify = true
+	if r, err = FindDuplicates(context.Background(), dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Groups) != 0 {
+		t.Errorf("got %d groups after verifying, want 0", len(r.Groups))
+	}
+}
+
+func TestDeleteKeepsChangedCopy(t *testing.T) {
+	dir := t.TempDir()
+	photos := filepath.Join(dir, "Photos", "x.jpg")
+	download := filepath.Join(dir, "Downloads", "x.jpg")
+	writeFile(t, download, "same")
+	writeFile(t, photos, "same")
+
+	r, err := FindDuplicates(context.Background(), dir, DuplicateOptions{KeepIn: []string{"Photos"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Groups) != 1 || r.Groups[0][0].Path != photos {
+		t.Fatalf("groups = %v, want one keeping %s", r.Groups, photos)
+	}
+
+	// The copy changes between the scan and the deletion
+	writeFile(t, download, "diff")
+	deleted, errs := r.Delete()
+	if len(deleted) != 0 || len(errs) != 1 {
+		t.Errorf("Delete deleted %v with errors %v, want nothing deleted and one error", deleted, errs)
+	}
+	for _, path := range []string{photos, download} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
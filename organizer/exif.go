@@ -0,0 +1,465 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// exifExts lists the extensions of the formats exifDate understands
+var exifExts = map[string]bool{
+	"jpg": true, "jpeg": true, "jpe": true, "tif": true, "tiff": true,
+	"heic": true, "heif": true, "avif": true, "png": true,
+	"cr2": true, "nef": true, "nrw": true, "arw": true, "srf": true, "sr2": true,
+	"orf": true, "dng": true, "rw2": true, "pef": true, "srw": true, "raf": true,
+}
+
+// EXIF tags read by exifDate
+const (
+	tagDateTime            = 0x0132
+	tagExifIFD             = 0x8769
+	tagDateTimeOriginal    = 0x9003
+	tagDateTimeDigitized   = 0x9004
+	tagOffsetTime          = 0x9010
+	tagOffsetTimeOriginal  = 0x9011
+	tagOffsetTimeDigitized = 0x9012
+)
+
+// maxExifSegment bounds the metadata read into memory from a single file
+const maxExifSegment = 16 << 20
+
+var errNoExif = errors.New("no EXIF date")
+
+// exifDate returns the time a photo was taken, from the EXIF metadata of
+// JPEG, TIFF, HEIF, PNG and TIFF-based raw files. Dates without an offset
+// tag are taken to be in loc.
+func exifDate(path string, loc *time.Location) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	var head [16]byte
+	n, _ := io.ReadFull(f, head[:])
+	h := head[:n]
+
+	var tiff []byte
+	switch {
+	case bytes.HasPrefix(h, []byte{0xFF, 0xD8}):
+		tiff, err = jpegExif(f, 0)
+	case bytes.HasPrefix(h, []byte("II")) || bytes.HasPrefix(h, []byte("MM")):
+		// TIFF and the raw formats built on it; Olympus and Panasonic
+		// raw files use their own magic numbers after the byte order
+		return tiffDate(io.NewSectionReader(f, 0, maxExifSegment), loc)
+	case bytes.HasPrefix(h, []byte("FUJIFILMCCD-RAW")):
+		tiff, err = rafExif(f)
+	case bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n")):
+		tiff, err = pngExif(f)
+	case n >= 8 && string(h[4:8]) == "ftyp":
+		tiff, err = heifExif(f)
+	default:
+		return time.Time{}, false
+	}
+	if err != nil {
+		return time.Time{}, false
+	}
+	return tiffDate(bytes.NewReader(tiff), loc)
+}
+
+// jpegExif returns the TIFF data of the Exif APP1 segment of the JPEG
+// starting at off
+func jpegExif(r io.ReaderAt, off int64) ([]byte, error) {
+	pos := off + 2
+	var hdr [4]byte
+	for {
+		if _, err := r.ReadAt(hdr[:2], pos); err != nil {
+			return nil, err
+		}
+		if hdr[0] != 0xFF {
+			return nil, errNoExif
+		}
+		marker := hdr[1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			pos++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			pos += 2
+			continue
+		case marker == 0xD9 || marker == 0xDA:
+			// End of image or start of the compressed data
+			return nil, errNoExif
+		}
+		if _, err := r.ReadAt(hdr[2:4], pos+2); err != nil {
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint16(hdr[2:4]))
+		if length < 2 {
+			return nil, errNoExif
+		}
+		if marker == 0xE1 && length > 8 {
+			data := make([]byte, length-2)
+			if _, err := r.ReadAt(data, pos+4); err != nil {
+				return nil, err
+			}
+			if tiff, ok := bytes.CutPrefix(data, []byte("Exif\x00\x00")); ok {
+				return tiff, nil
+			}
+		}
+		pos += 2 + length
+	}
+}
+
+// rafExif returns the EXIF data of the JPEG preview embedded in a Fujifilm
+// raw file
+func rafExif(r io.ReaderAt) ([]byte, error) {
+	var off [4]byte
+	if _, err := r.ReadAt(off[:], 84); err != nil {
+		return nil, err
+	}
+	return jpegExif(r, int64(binary.BigEndian.Uint32(off[:])))
+}
+
+// pngExif returns the contents of the eXIf chunk of a PNG image
+func pngExif(r io.ReaderAt) ([]byte, error) {
+	pos := int64(8)
+	var hdr [8]byte
+	for {
+		if _, err := r.ReadAt(hdr[:], pos); err != nil {
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint32(hdr[:4]))
+		switch string(hdr[4:8]) {
+		case "eXIf":
+			if length > maxExifSegment {
+				return nil, errNoExif
+			}
+			data := make([]byte, length)
+			_, err := r.ReadAt(data, pos+8)
+			return data, err
+		case "IEND":
+			return nil, errNoExif
+		}
+		pos += 12 + length
+	}
+}
+
+// heifExif returns the TIFF data of the Exif item of a HEIF image, such
+// as HEIC photos and AVIF images
+func heifExif(r io.ReaderAt) ([]byte, error) {
+	meta, err := findBox(r, 0, -1, "meta")
+	if err != nil {
+		return nil, err
+	}
+	if len(meta) < 4 {
+		return nil, errNoExif
+	}
+	// meta is a full box: skip its version and flags
+	meta = meta[4:]
+
+	iinf, err := findBox(bytes.NewReader(meta), 0, int64(len(meta)), "iinf")
+	if err != nil {
+		return nil, err
+	}
+	id, ok := heifExifItem(iinf)
+	if !ok {
+		return nil, errNoExif
+	}
+	iloc, err := findBox(bytes.NewReader(meta), 0, int64(len(meta)), "iloc")
+	if err != nil {
+		return nil, err
+	}
+	off, length, ok := heifItemLocation(iloc, id)
+	if !ok || length < 4 || length > maxExifSegment {
+		return nil, errNoExif
+	}
+
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, off); err != nil {
+		return nil, err
+	}
+	// The item starts with the offset of the TIFF header, usually past an
+	// "Exif\0\0" marker
+	skip := int64(binary.BigEndian.Uint32(data[:4]))
+	if skip > length-4 {
+		return nil, errNoExif
+	}
+	return data[4+skip:], nil
+}
+
+// findBox returns the contents of the first ISO base media box of type
+// name between start and end, or the end of r when end is negative
+func findBox(r io.ReaderAt, start, end int64, name string) ([]byte, error) {
+	off, size, err := locateBox(r, start, end, name)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxExifSegment {
+		return nil, errNoExif
+	}
+	data := make([]byte, size)
+	n, err := r.ReadAt(data, off)
+	if err != nil && !(errors.Is(err, io.EOF) && n > 0) {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// locateBox returns the offset and size of the contents of the first ISO
+// base media box of type name between start and end, or the end of r when
+// end is negative, without reading them
+func locateBox(r io.ReaderAt, start, end int64, name string) (int64, int64, error) {
+	pos := start
+	var hdr [16]byte
+	for end < 0 || pos+8 <= end {
+		if _, err := r.ReadAt(hdr[:8], pos); err != nil {
+			return 0, 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// The box extends to the end of the file
+			if end < 0 {
+				size = maxExifSegment
+			} else {
+				size = end - pos
+			}
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], pos+8); err != nil {
+				return 0, 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return 0, 0, errNoExif
+		}
+		if string(hdr[4:8]) == name {
+			return pos + headerSize, size - headerSize, nil
+		}
+		pos += size
+	}
+	return 0, 0, errNoExif
+}
+
+// heifExifItem returns the ID of the Exif item listed in an iinf box
+func heifExifItem(iinf []byte) (uint32, bool) {
+	if len(iinf) < 6 {
+		return 0, false
+	}
+	pos := 6
+	if iinf[0] != 0 {
+		pos = 8
+	}
+	for pos+8 <= len(iinf) {
+		size := int(binary.BigEndian.Uint32(iinf[pos:]))
+		if size < 8 || pos+size > len(iinf) {
+			return 0, false
+		}
+		typ, box := string(iinf[pos+4:pos+8]), iinf[pos+8:pos+size]
+		pos += size
+		if typ != "infe" || len(box) < 4 {
+			continue
+		}
+		version := box[0]
+		var id uint32
+		var rest []byte
+		switch {
+		case version == 2 && len(box) >= 12:
+			id, rest = uint32(binary.BigEndian.Uint16(box[4:])), box[8:]
+		case version == 3 && len(box) >= 14:
+			id, rest = binary.BigEndian.Uint32(box[4:]), box[10:]
+		default:
+			continue
+		}
+		if string(rest[:4]) == "Exif" {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// heifItemLocation returns the file offset and length of item id from an
+// iloc box. Only items stored in a single extent of the file itself are
+// supported.
+func heifItemLocation(iloc []byte, id uint32) (int64, int64, bool) {
+	if len(iloc) < 8 {
+		return 0, 0, false
+	}
+	version := iloc[0]
+	offsetSize := int(iloc[4] >> 4)
+	lengthSize := int(iloc[4] & 0x0F)
+	baseOffsetSize := int(iloc[5] >> 4)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(iloc[5] & 0x0F)
+	}
+
+	b := iloc[6:]
+	ok := true
+	read := func(size int) uint64 {
+		if size == 0 {
+			return 0
+		}
+		if len(b) < size {
+			ok = false
+			return 0
+		}
+		var v uint64
+		for _, c := range b[:size] {
+			v = v<<8 | uint64(c)
+		}
+		b = b[size:]
+		return v
+	}
+
+	count := read(2)
+	if version == 2 {
+		count = count<<16 | read(2)
+	}
+	for i := uint64(0); i < count && ok; i++ {
+		idSize := 2
+		if version == 2 {
+			idSize = 4
+		}
+		itemID := uint32(read(idSize))
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = read(2) & 0x0F
+		}
+		read(2) // data reference index
+		base := read(baseOffsetSize)
+		extents := read(2)
+		var off, length uint64
+		for e := uint64(0); e < extents && ok; e++ {
+			if indexSize > 0 {
+				read(indexSize)
+			}
+			off, length = read(offsetSize), read(lengthSize)
+		}
+		if ok && itemID == id {
+			if method != 0 || extents != 1 {
+				return 0, 0, false
+			}
+			return int64(base + off), int64(length), true
+		}
+	}
+	return 0, 0, false
+}
+
+// tiffReader reads the IFDs of TIFF data
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// tiffEntry is a single IFD entry
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value [4]byte
+}
+
+// tiffDate returns the time a photo was taken from TIFF data: the
+// original date of the Exif IFD, or the digitized or file change date
+// when it is missing, in loc unless an offset is recorded
+func tiffDate(r io.ReaderAt, loc *time.Location) (time.Time, bool) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return time.Time{}, false
+	}
+	t := tiffReader{r: r}
+	switch string(hdr[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+
+	ifd0, err := t.ifd(int64(t.order.Uint32(hdr[4:])))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var exif map[uint16]tiffEntry
+	if e, ok := ifd0[tagExifIFD]; ok {
+		exif, _ = t.ifd(int64(t.order.Uint32(e.value[:])))
+	}
+	for _, tags := range [][2]uint16{
+		{tagDateTimeOriginal, tagOffsetTimeOriginal},
+		{tagDateTimeDigitized, tagOffsetTimeDigitized},
+	} {
+		if date, ok := parseExifTime(t.ascii(exif, tags[0]), t.ascii(exif, tags[1]), loc); ok {
+			return date, true
+		}
+	}
+	return parseExifTime(t.ascii(ifd0, tagDateTime), t.ascii(exif, tagOffsetTime), loc)
+}
+
+// ifd reads the entries of the IFD at off
+func (t tiffReader) ifd(off int64) (map[uint16]tiffEntry, error) {
+	var buf [12]byte
+	if _, err := t.r.ReadAt(buf[:2], off); err != nil {
+		return nil, err
+	}
+	count := int(t.order.Uint16(buf[:2]))
+	if count > 1000 {
+		return nil, errNoExif
+	}
+	entries := make(map[uint16]tiffEntry, count)
+	for i := 0; i < count; i++ {
+		if _, err := t.r.ReadAt(buf[:], off+2+int64(i)*12); err != nil {
+			return nil, err
+		}
+		e := tiffEntry{typ: t.order.Uint16(buf[2:]), count: t.order.Uint32(buf[4:])}
+		copy(e.value[:], buf[8:12])
+		entries[t.order.Uint16(buf[:2])] = e
+	}
+	return entries, nil
+}
+
+// ascii returns the string value of tag, or "" if it is missing
+func (t tiffReader) ascii(ifd map[uint16]tiffEntry, tag uint16) string {
+	e, ok := ifd[tag]
+	// Type 2 is ASCII
+	if !ok || e.typ != 2 || e.count == 0 || e.count > 64 {
+		return ""
+	}
+	data := e.value[:]
+	if e.count > 4 {
+		data = make([]byte, e.count)
+		if _, err := t.r.ReadAt(data, int64(t.order.Uint32(e.value[:]))); err != nil {
+			return ""
+		}
+	}
+	return strings.TrimRight(string(data[:min(int(e.count), len(data))]), "\x00 ")
+}
+
+// parseExifTime parses an EXIF date such as "2024:05:14 15:30:12" with
+// its optional offset such as "+02:00". Dates without an offset are in
+// loc.
+func parseExifTime(value, offset string, loc *time.Location) (time.Time, bool) {
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if t, err := time.Parse("-07:00", offset); err == nil {
+			_, secs := t.Zone()
+			loc = time.FixedZone(offset, secs)
+		}
+	}
+	for _, layout := range []string{"2006:01:02 15:04:05", "2006-01-02 15:04:05", "2006:01:02 15:04", "2006:01:02"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
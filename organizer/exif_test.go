@@ -0,0 +1,378 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tiffTag is an ASCII tag of a test TIFF, or the pointer to its Exif IFD
+// when tag is tagExifIFD
+type tiffTag struct {
+	tag   uint16
+	value string
+}
+
+// buildTIFF returns TIFF data in byte order "II" or "MM" with the tags of
+// IFD0 and, when exif is not nil, an Exif IFD
+func buildTIFF(byteOrder string, ifd0, exif []tiffTag) []byte {
+	var order binary.AppendByteOrder = binary.LittleEndian
+	if byteOrder == "MM" {
+		order = binary.BigEndian
+	}
+	if exif != nil {
+		ifd0 = append(ifd0, tiffTag{tag: tagExifIFD})
+	}
+	ifdSize := func(tags []tiffTag) int { return 2 + 12*len(tags) + 4 }
+	exifOff := 8 + ifdSize(ifd0)
+	dataOff := exifOff
+	if exif != nil {
+		dataOff += ifdSize(exif)
+	}
+
+	var data []byte
+	writeIFD := func(b []byte, tags []tiffTag) []byte {
+		b = order.AppendUint16(b, uint16(len(tags)))
+		for _, tag := range tags {
+			b = order.AppendUint16(b, tag.tag)
+			if tag.tag == tagExifIFD {
+				// One LONG
+				b = order.AppendUint16(b, 4)
+				b = order.AppendUint32(b, 1)
+				b = order.AppendUint32(b, uint32(exifOff))
+				continue
+			}
+			value := []byte(tag.value + "\x00")
+			b = order.AppendUint16(b, 2)
+			b = order.AppendUint32(b, uint32(len(value)))
+			if len(value) <= 4 {
+				b = append(b, make([]byte, 4)...)
+				copy(b[len(b)-4:], value)
+			} else {
+				b = order.AppendUint32(b, uint32(dataOff+len(data)))
+				data = append(data, value...)
+			}
+		}
+		// No next IFD
+		return order.AppendUint32(b, 0)
+	}
+
+	b := order.AppendUint16([]byte(byteOrder), 42)
+	b = order.AppendUint32(b, 8)
+	b = writeIFD(b, ifd0)
+	if exif != nil {
+		b = writeIFD(b, exif)
+	}
+	return append(b, data...)
+}
+
+// testTIFF is TIFF data dated 2024-05-14 15:30:12, both as the original
+// date and as the file change date it falls back to
+func testTIFF() []byte {
+	return buildTIFF("II",
+		[]tiffTag{{tagDateTime, "2024:05:14 15:30:12"}},
+		[]tiffTag{{tagDateTimeOriginal, "2024:05:14 15:30:12"}})
+}
+
+var testTIFFDate = time.Date(2024, 5, 14, 15, 30, 12, 0, time.UTC)
+
+func TestTIFFDate(t *testing.T) {
+	zone := time.FixedZone("", 2*3600)
+	tests := []struct {
+		name string
+		data []byte
+		want time.Time
+		ok   bool
+	}{
+		{"original", buildTIFF("II",
+			[]tiffTag{{tagDateTime, "2020:01:01 00:00:00"}},
+			[]tiffTag{{tagDateTimeOriginal, "2024:05:14 15:30:12"}}), testTIFFDate, true},
+		{"big endian", buildTIFF("MM",
+			nil,
+			[]tiffTag{{tagDateTimeOriginal, "2024:05:14 15:30:12"}}), testTIFFDate, true},
+		{"offset", buildTIFF("II",
+			nil,
+			[]tiffTag{{tagDateTimeOriginal, "2024:05:14 15:30:12"}, {tagOffsetTimeOriginal, "+02:00"}}),
+			time.Date(2024, 5, 14, 15, 30, 12, 0, zone), true},
+		{"digitized", buildTIFF("MM",
+			[]tiffTag{{tagDateTime, "2020:01:01 00:00:00"}},
+			[]tiffTag{{tagDateTimeOriginal, "0000:00:00 00:00:00"}, {tagDateTimeDigitized, "2024:05:14 15:30:12"}}),
+			testTIFFDate, true},
+		{"file change date", buildTIFF("II",
+			[]tiffTag{{tagDateTime, "2024:05:14 15:30:12"}},
+			nil), testTIFFDate, true},
+		{"no dates", buildTIFF("II", nil, []tiffTag{}), time.Time{}, false},
+		{"bad date", buildTIFF("II", []tiffTag{{tagDateTime, "yesterday"}}, nil), time.Time{}, false},
+		{"empty", nil, time.Time{}, false},
+		{"header only", []byte("II*\x00"), time.Time{}, false},
+		{"bad byte order", append([]byte("XX"), testTIFF()[2:]...), time.Time{}, false},
+		{"IFD past the end", []byte("II*\x00\xff\x00\x00\x00"), time.Time{}, false},
+		{"huge IFD", []byte("II*\x00\x08\x00\x00\x00\xff\xff"), time.Time{}, false},
+		{"truncated IFD", testTIFF()[:20], time.Time{}, false},
+		{"truncated string", testTIFF()[:len(testTIFF())-30], time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := tiffDate(bytes.NewReader(tt.data), time.UTC)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("%s: tiffDate = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseExifTime(t *testing.T) {
+	zone := time.FixedZone("", -5*3600)
+	tests := []struct {
+		value, offset string
+		want          time.Time
+		ok            bool
+	}{
+		{"2024:05:14 15:30:12", "", testTIFFDate, true},
+		{"2024:05:14 15:30:12", "-05:00", time.Date(2024, 5, 14, 15, 30, 12, 0, zone), true},
+		{"2024:05:14 15:30:12", "garbage", testTIFFDate, true},
+		{"2024-05-14 15:30:12", "", testTIFFDate, true},
+		{"2024:05:14 15:30", "", time.Date(2024, 5, 14, 15, 30, 0, 0, time.UTC), true},
+		{"2024:05:14", "", time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC), true},
+		{"", "", time.Time{}, false},
+		{"0000:00:00 00:00:00", "", time.Time{}, false},
+		{"2024:13:14 15:30:12", "", time.Time{}, false},
+		{"    :  :     :  :  ", "", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseExifTime(tt.value, tt.offset, time.UTC)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseExifTime(%q, %q) = %v, %v, want %v, %v", tt.value, tt.offset, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func be16(v int) []byte { return binary.BigEndian.AppendUint16(nil, uint16(v)) }
+func be32(v int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(v)) }
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// testJPEG returns a JPEG whose APP1 segment holds tiff
+func testJPEG(tiff []byte) []byte {
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	return join(
+		[]byte{0xFF, 0xD8},
+		[]byte{0xFF, 0xE0}, be16(16), []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"),
+		[]byte{0xFF, 0xFF},
+		[]byte{0xFF, 0xE1}, be16(2+len(app1)), app1,
+		[]byte{0xFF, 0xDA}, be16(2),
+	)
+}
+
+// pngChunk returns a PNG chunk with a zero CRC, which pngExif ignores
+func pngChunk(typ string, data []byte) []byte {
+	return join(be32(len(data)), []byte(typ), data, be32(0))
+}
+
+func testPNG(tiff []byte) []byte {
+	return join(
+		[]byte("\x89PNG\r\n\x1a\n"),
+		pngChunk("IHDR", make([]byte, 13)),
+		pngChunk("eXIf", tiff),
+		pngChunk("IEND", nil),
+	)
+}
+
+// testRAF returns a Fujifilm raw file whose JPEG preview holds tiff
+func testRAF(tiff []byte) []byte {
+	head := make([]byte, 100)
+	copy(head, "FUJIFILMCCD-RAW 0201FF383501")
+	copy(head[84:], be32(len(head)))
+	return append(head, testJPEG(tiff)...)
+}
+
+func isoBox(typ string, content ...[]byte) []byte {
+	data := join(content...)
+	return join(be32(8+len(data)), []byte(typ), data)
+}
+
+// testHEIF returns a HEIF image whose Exif item, described by an infe box
+// of version infeVersion, holds tiff
+func testHEIF(tiff []byte, infeVersion byte) []byte {
+	item := join(be32(6), []byte("Exif\x00\x00"), tiff)
+	ftyp := isoBox("ftyp", []byte("heic"), be32(0), []byte("mif1heic"))
+
+	meta := func(itemOff int) []byte {
+		var infe []byte
+		if infeVersion == 3 {
+			infe = isoBox("infe", []byte{3, 0, 0, 0}, be32(7), be16(0), []byte("Exif"))
+		} else {
+			infe = isoBox("infe", []byte{2, 0, 0, 0}, be16(7), be16(0), []byte("Exif"))
+		}
+		return isoBox("meta",
+			be32(0),
+			isoBox("hdlr", be32(0), be32(0), []byte("pict"), make([]byte, 13)),
+			isoBox("iinf", be32(0), be16(2),
+				isoBox("infe", []byte{2, 0, 0, 0}, be16(1), be16(0), []byte("hvc1")),
+				infe),
+			// Offsets and lengths of 4 bytes, no base offset
+			isoBox("iloc", be32(0), []byte{0x44, 0x00}, be16(2),
+				be16(1), be16(0), be16(1), be32(0), be32(0),
+				be16(7), be16(0), be16(1), be32(itemOff), be32(len(item))),
+		)
+	}
+	itemOff := len(ftyp) + len(meta(0)) + 8
+	return join(ftyp, meta(itemOff), isoBox("mdat", item))
+}
+
+func TestExifDateContainers(t *testing.T) {
+	tiff := testTIFF()
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"photo.tif", tiff, true},
+		{"photo.jpg", testJPEG(tiff), true},
+		{"photo.png", testPNG(tiff), true},
+		{"photo.raf", testRAF(tiff), true},
+		{"photo.heic", testHEIF(tiff, 2), true},
+		{"photo-v3.heic", testHEIF(tiff, 3), true},
+
+		{"empty.jpg", nil, false},
+		{"text.jpg", []byte("not an image at all"), false},
+		{"no-app1.jpg", testJPEG(nil)[:22], false},
+		{"not-exif.jpg", join([]byte{0xFF, 0xD8, 0xFF, 0xE1}, be16(12), []byte("XMP\x00 data!"), []byte{0xFF, 0xD9}), false},
+		{"short-segment.jpg", join([]byte{0xFF, 0xD8, 0xFF, 0xE0}, be16(1)), false},
+		{"no-marker.jpg", []byte{0xFF, 0xD8, 0x00, 0x00, 0x00, 0x00}, false},
+		{"no-exif.png", join([]byte("\x89PNG\r\n\x1a\n"), pngChunk("IHDR", make([]byte, 13)), pngChunk("IEND", nil)), false},
+		{"huge-exif.png", join([]byte("\x89PNG\r\n\x1a\n"), be32(1<<30), []byte("eXIf")), false},
+		{"bad-offset.raf", append(testRAF(tiff)[:84], be32(1<<20)...), false},
+		{"no-meta.heic", isoBox("ftyp", []byte("heic"), be32(0)), false},
+		{"short-box.heic", join(isoBox("ftyp", []byte("heic"), be32(0)), be32(4), []byte("meta")), false},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := exifDate(path, time.UTC)
+		if ok != tt.ok || (ok && !got.Equal(testTIFFDate)) {
+			t.Errorf("%s: exifDate = %v, %v, want %v", tt.name, got, ok, tt.ok)
+		}
+	}
+}
+
+func TestExifDateTruncated(t *testing.T) {
+	tiff := testTIFF()
+	path := filepath.Join(t.TempDir(), "photo")
+	for name, data := range map[string][]byte{
+		"tiff": tiff,
+		"jpeg": testJPEG(tiff),
+		"png":  testPNG(tiff),
+		"raf":  testRAF(tiff),
+		"heif": testHEIF(tiff, 2),
+	} {
+		// Every prefix must be parsed without panicking, and any date
+		// found must be the right one
+		for n := range data {
+			if err := os.WriteFile(path, data[:n], 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := exifDate(path, time.UTC); ok && !got.Equal(testTIFFDate) {
+				t.Errorf("%s cut at %d bytes: exifDate = %v", name, n, got)
+			}
+		}
+	}
+}
+
+func TestHeifItemLocation(t *testing.T) {
+	tests := []struct {
+		name        string
+		iloc        []byte
+		off, length int64
+		ok          bool
+	}{
+		{"version 0", join(be32(0), []byte{0x44, 0x00}, be16(1),
+			be16(7), be16(0), be16(1), be32(100), be32(20)), 100, 20, true},
+		{"base offset", join(be32(0), []byte{0x44, 0x40}, be16(1),
+			be16(7), be16(0), be32(1000), be16(1), be32(100), be32(20)), 1100, 20, true},
+		{"version 1", join([]byte{1, 0, 0, 0}, []byte{0x44, 0x00}, be16(1),
+			be16(7), be16(0), be16(0), be16(1), be32(100), be32(20)), 100, 20, true},
+		{"version 2", join([]byte{2, 0, 0, 0}, []byte{0x88, 0x00}, be32(1),
+			be32(7), be16(0), be16(0), be16(1), make([]byte, 7), []byte{100}, make([]byte, 7), []byte{20}), 100, 20, true},
+		{"construction method", join([]byte{1, 0, 0, 0}, []byte{0x44, 0x00}, be16(1),
+			be16(7), be16(1), be16(0), be16(1), be32(100), be32(20)), 0, 0, false},
+		{"two extents", join(be32(0), []byte{0x44, 0x00}, be16(1),
+			be16(7), be16(0), be16(2), be32(100), be32(20), be32(200), be32(20)), 0, 0, false},
+		{"other item", join(be32(0), []byte{0x44, 0x00}, be16(1),
+			be16(8), be16(0), be16(1), be32(100), be32(20)), 0, 0, false},
+		{"truncated", join(be32(0), []byte{0x44, 0x00}, be16(1),
+			be16(7), be16(0), be16(1), be32(100)), 0, 0, false},
+		{"too many items", join(be32(0), []byte{0x44, 0x00}, be16(0xFFFF)), 0, 0, false},
+		{"short", be32(0), 0, 0, false},
+	}
+	for _, tt := range tests {
+		off, length, ok := heifItemLocation(tt.iloc, 7)
+		if ok != tt.ok || off != tt.off || length != tt.length {
+			t.Errorf("%s: heifItemLocation = %d, %d, %v, want %d, %d, %v", tt.name, off, length, ok, tt.off, tt.length, tt.ok)
+		}
+	}
+}
+
+func TestHeifExifItem(t *testing.T) {
+	infe := func(version byte, id int, typ string) []byte {
+		if version == 3 {
+			return isoBox("infe", []byte{3, 0, 0, 0}, be32(id), be16(0), []byte(typ))
+		}
+		return isoBox("infe", []byte{version, 0, 0, 0}, be16(id), be16(0), []byte(typ))
+	}
+	tests := []struct {
+		name string
+		iinf []byte
+		id   uint32
+		ok   bool
+	}{
+		{"version 2", join(be32(0), be16(2), infe(2, 1, "hvc1"), infe(2, 7, "Exif")), 7, true},
+		{"version 3", join(be32(0), be16(1), infe(3, 70000, "Exif")), 70000, true},
+		{"iinf version 1", join([]byte{1, 0, 0, 0}, be32(1), infe(2, 7, "Exif")), 7, true},
+		{"no Exif", join(be32(0), be16(1), infe(2, 1, "hvc1")), 0, false},
+		{"old infe", join(be32(0), be16(1), infe(1, 7, "Exif")), 0, false},
+		{"short infe", join(be32(0), be16(1), isoBox("infe", []byte{2, 0, 0, 0})), 0, false},
+		{"box past the end", join(be32(0), be16(1), be32(100), []byte("infe")), 0, false},
+		{"box too small", join(be32(0), be16(1), be32(4), []byte("infe")), 0, false},
+		{"short", []byte{0, 0}, 0, false},
+	}
+	for _, tt := range tests {
+		id, ok := heifExifItem(tt.iinf)
+		if ok != tt.ok || id != tt.id {
+			t.Errorf("%s: heifExifItem = %d, %v, want %d, %v", tt.name, id, ok, tt.id, tt.ok)
+		}
+	}
+}
+
+func TestLocateBox(t *testing.T) {
+	free := isoBox("free", make([]byte, 4))
+	tests := []struct {
+		name       string
+		data       []byte
+		off, size  int64
+		ok         bool
+		start, end int64
+	}{
+		{"first", isoBox("meta", []byte("abc")), 8, 3, true, 0, -1},
+		{"after others", join(free, isoBox("meta", []byte("abc"))), 20, 3, true, 0, -1},
+		{"large size", join(be32(1), []byte("meta"), binary.BigEndian.AppendUint64(nil, 19), []byte("abc")), 16, 3, true, 0, -1},
+		{"to the end", join(free, be32(0), []byte("meta"), []byte("abc")), 20, 3, true, 0, 23},
+		{"outside the range", join(free, isoBox("meta", []byte("abc"))), 0, 0, false, 0, 12},
+		{"missing", free, 0, 0, false, 0, -1},
+		{"size too small", join(be32(7), []byte("free"), isoBox("meta")), 0, 0, false, 0, -1},
+		{"large size too small", join(be32(1), []byte("meta"), binary.BigEndian.AppendUint64(nil, 8)), 0, 0, false, 0, -1},
+		{"truncated header", []byte{0, 0, 0}, 0, 0, false, 0, -1},
+	}
+	for _, tt := range tests {
+		off, size, err := locateBox(bytes.NewReader(tt.data), tt.start, tt.end, "meta")
+		if (err == nil) != tt.ok || off != tt.off || size != tt.size {
+			t.Errorf("%s: locateBox = %d, %d, %v, want %d, %d, ok %v", tt.name, off, size, err, tt.off, tt.size, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package organizer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FetchOptions configures Fetch
+type FetchOptions struct {
+	// Cleanup removes the directories left empty after fetching
+	Cleanup bool
+	// Mode is how files are fetched, see Options.Mode
+	Mode string
+	// OnConflict decides what happens when a file of the same name is
+	// already in dir, see Options.OnConflict
+	OnConflict string
+	// ExcludeDirs prunes matching directories, see Options.ExcludeDirs
+	ExcludeDirs []string
+}
+
+// PlanFetch plans moving every file found in the subdirectories of dir
+// into dir itself
+func PlanFetch(ctx context.Context, dir string, opts FetchOptions) (*Plan, error) {
+	if err := checkMode(opts.Mode); err != nil {
+		return nil, err
+	}
+	if err := checkPlanPolicy(opts.OnConflict); err != nil {
+		return nil, err
+	}
+	mode := opts.Mode
+	if mode == "" {
+		mode = OpMove
+	}
+	p := newPlanner(filepath.Join(dir, JournalName))
+	p.plan.Kind = KindFetch
+	p.onConflict = opts.OnConflict
+
+	// Walk through all subdirectories
+	err := walkTree(ctx, dir, opts.ExcludeDirs, func(path string, info os.FileInfo) error {
+		// Skip gorder's own files
+		if isGorderFile(filepath.Base(path)) {
+			return nil
+		}
+
+		// If it's a file and not in current directory, add to move list
+		if !info.IsDir() && filepath.Dir(path) != filepath.Clean(dir) {
+			p.add(Move{
+				Source: path,
+				Dest:   filepath.Join(dir, filepath.Base(path)),
+				Op:     mode,
+				Reason: "fetched from " + filepath.Dir(path),
+			}, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+	return p.plan, nil
+}
+
+// Fetch flattens dir by pulling every file out of its subdirectories. The
+// moves and the directories removed by Cleanup are journaled together so
+// a single undo restores the original tree.
+func Fetch(ctx context.Context, dir string, opts FetchOptions) (*Result, error) {
+	plan, err := PlanFetch(ctx, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	j, err := plan.openJournal()
+	if err != nil {
+		return nil, err
+	}
+	res, err := execute(ctx, plan, j)
+	if err != nil {
+		j.finish(res)
+		return res, err
+	}
+
+	// Cleanup empty directories if requested
+	if opts.Cleanup {
+		removeEmptyDirs(ctx, dir, opts.ExcludeDirs, res, j)
+	}
+	j.finish(res)
+	return res, nil
+}
+
+// removeEmptyDirs removes the empty directories below root, leaving the
+// excluded and ignored ones alone, and journals them in j
+func removeEmptyDirs(ctx context.Context, root string, excludeDirs []string, res *Result, j *journal) {
+	// Walk bottom-up to remove nested empty directories
+	var dirs []string
+	infos := make(map[string]os.FileInfo)
+
+	err := walkTree(ctx, root, excludeDirs, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			infos[path] = info
+		}
+		return nil
+	})
+	if err != nil {
+		res.Errors = append(res.Errors, fmt.Errorf("error walking directory: %w", err))
+		return
+	}
+
+	// Reverse order to process deepest directories first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+
+		// Check if directory is empty
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("error reading directory %s: %w", dir, err))
+			continue
+		}
+
+		if len(entries) == 0 {
+			if err := os.Remove(dir); err != nil {
+				res.Errors = append(res.Errors, fmt.Errorf("error removing empty directory %s: %w", dir, err))
+			} else {
+				res.Removed = append(res.Removed, dir)
+				removed := RemovedDir{Path: dir, Mode: infos[dir].Mode().Perm(), ModTime: infos[dir].ModTime()}
+				if abs, err := filepath.Abs(dir); err == nil {
+					removed.Path = abs
+				}
+				if err := j.removed(removed); err != nil {
+					res.Errors = append(res.Errors, fmt.Errorf("could not journal %s: %w", dir, err))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+//go:build darwin || freebsd || netbsd
+
+package organizer
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// changeTime returns the time the file's metadata last changed
+func changeTime(path string, info os.FileInfo) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(st.Ctimespec.Unix()), true
+}
+
+// birthTime returns the time the file was created
+func birthTime(path string, info os.FileInfo) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Birthtimespec.Sec <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(st.Birthtimespec.Unix()), true
+}
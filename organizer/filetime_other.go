@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !windows
+
+package organizer
+
+import (
+	"os"
+	"time"
+)
+
+// changeTime reports false where the change time is not known
+func changeTime(path string, info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// birthTime reports false where the creation time is not known
+func birthTime(path string, info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,22 @@
+package organizer
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// changeTime reports false: Windows does not expose a metadata change
+// time through os.FileInfo
+func changeTime(path string, info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// birthTime returns the time the file was created
+func birthTime(path string, info os.FileInfo) (time.Time, bool) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, data.CreationTime.Nanoseconds()), true
+}
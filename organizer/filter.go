@@ -0,0 +1,213 @@
+package organizer
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RegexPrefix marks an include or exclude entry as a regular expression
+const RegexPrefix = "re:"
+
+// patternKind tells how an include or exclude entry is matched
+type patternKind int
+
+const (
+	// literalPattern matches an extension (jpg, .jpg, tar.gz) or an exact name
+	literalPattern patternKind = iota
+	// noExtPattern is "." and matches files without an extension
+	noExtPattern
+	// globPattern is a shell glob; "**" spans directories
+	globPattern
+	// regexPattern is matched against the relative path
+	regexPattern
+)
+
+// pattern is a parsed include or exclude entry
+type pattern struct {
+	raw  string
+	kind patternKind
+	re   *regexp.Regexp
+}
+
+// filter decides which files are processed from the include and exclude
+// entries
+type filter struct {
+	include       []pattern
+	exclude       []pattern
+	caseSensitive bool
+}
+
+func newFilter(include, exclude []string, caseSensitive bool) (*filter, error) {
+	f := &filter{caseSensitive: caseSensitive}
+	var err error
+	if f.include, err = parsePatterns(include); err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	if f.exclude, err = parsePatterns(exclude); err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+	return f, nil
+}
+
+// parsePatterns parses include or exclude entries. Entries starting with
+// "re:" are regular expressions, entries containing *, ? or [ are globs,
+// "." stands for files without an extension and anything else is an
+// extension (with or without the dot) or an exact file name.
+func parsePatterns(items []string) ([]pattern, error) {
+	var patterns []pattern
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case strings.HasPrefix(item, RegexPrefix):
+			re, err := regexp.Compile(strings.TrimPrefix(item, RegexPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", item, err)
+			}
+			patterns = append(patterns, pattern{raw: item, kind: regexPattern, re: re})
+		case item == ".":
+			patterns = append(patterns, pattern{raw: item, kind: noExtPattern})
+		case strings.ContainsAny(item, "*?["):
+			if err := validGlob(item); err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, pattern{raw: item, kind: globPattern})
+		default:
+			patterns = append(patterns, pattern{raw: item, kind: literalPattern})
+		}
+	}
+	return patterns, nil
+}
+
+// allows reports whether a file should be processed. name is its base
+// name and rel its slash-separated path relative to the organized
+// directory.
+func (f *filter) allows(name, rel string) bool {
+	return f.rejects(name, rel) == ""
+}
+
+// rejects returns why a file is not processed, or "" if it is. name and
+// rel are as for allows.
+func (f *filter) rejects(name, rel string) string {
+	// Skip hidden files by default unless explicitly included
+	if strings.HasPrefix(name, ".") && !f.matchAny(f.include, name, rel) {
+		return "hidden file"
+	}
+
+	// Check exclude list
+	if p, ok := f.firstMatch(f.exclude, name, rel); ok {
+		return fmt.Sprintf("excluded by --exclude %q", p.raw)
+	}
+
+	// If include list is specified, only process included files
+	if len(f.include) > 0 && !f.matchAny(f.include, name, rel) {
+		return "not matched by --include"
+	}
+
+	return ""
+}
+
+func (f *filter) matchAny(patterns []pattern, name, rel string) bool {
+	_, ok := f.firstMatch(patterns, name, rel)
+	return ok
+}
+
+// firstMatch returns the first of patterns matching the file
+func (f *filter) firstMatch(patterns []pattern, name, rel string) (pattern, bool) {
+	for _, p := range patterns {
+		if f.match(p, name, rel) {
+			return p, true
+		}
+	}
+	return pattern{}, false
+}
+
+func (f *filter) match(p pattern, name, rel string) bool {
+	switch p.kind {
+	case regexPattern:
+		return p.re.MatchString(rel)
+	case noExtPattern:
+		return !strings.HasPrefix(name, ".") && path.Ext(name) == ""
+	case globPattern:
+		return matchGlob(p.raw, name, rel)
+	}
+
+	if name == p.raw {
+		return true
+	}
+	ext := "." + strings.TrimPrefix(p.raw, ".")
+	if f.caseSensitive {
+		return strings.HasSuffix(name, ext) && len(name) > len(ext)
+	}
+	return strings.HasSuffix(strings.ToLower(name), strings.ToLower(ext)) && len(name) > len(ext)
+}
+
+// matchGlob matches a shell glob against the base name of a file, or
+// against its relative path when the glob contains a slash. "**" matches
+// any number of directories, and wildcards do not match a leading dot.
+func matchGlob(glob, name, rel string) bool {
+	if !strings.Contains(glob, "/") {
+		return matchSegment(glob, name)
+	}
+	return matchParts(strings.Split(glob, "/"), strings.Split(rel, "/"), matchSegment)
+}
+
+// matchParts matches the elements of a path against the elements of a
+// glob with match, letting "**" stand for any number of elements
+func matchParts(globs, parts []string, match func(glob, part string) bool) bool {
+	for len(globs) > 0 {
+		if globs[0] == "**" {
+			// Collapse consecutive ** and try every split point
+			for len(globs) > 0 && globs[0] == "**" {
+				globs = globs[1:]
+			}
+			if len(globs) == 0 {
+				return true
+			}
+			for i := range parts {
+				if matchParts(globs, parts[i:], match) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 || !match(globs[0], parts[0]) {
+			return false
+		}
+		globs, parts = globs[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func matchSegment(glob, part string) bool {
+	if strings.HasPrefix(part, ".") && !strings.HasPrefix(glob, ".") {
+		return false
+	}
+	ok, _ := path.Match(glob, part)
+	return ok
+}
+
+// validGlob checks the syntax of every segment of a glob
+func validGlob(glob string) error {
+	for _, segment := range strings.Split(glob, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+	return nil
+}
+
+// ParseList splits a comma-separated flag value into its items
+func ParseList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package organizer
+
+import (
+	"path"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob, rel string
+		want      bool
+	}{
+		// Globs without a slash match the base name
+		{"*.jpg", "a.jpg", true},
+		{"*.jpg", "deep/dir/a.jpg", true},
+		{"*.jpg", "a.jpeg", false},
+		{"IMG_????.*", "IMG_0001.png", true},
+		{"*", ".hidden", false},
+		{".*", ".hidden", true},
+
+		// Globs with a slash match the relative path
+		{"photos/*.jpg", "photos/a.jpg", true},
+		{"photos/*.jpg", "photos/2024/a.jpg", false},
+		{"photos/*.jpg", "other/photos/a.jpg", false},
+		{"*/a.jpg", "photos/a.jpg", true},
+		{"*/a.jpg", ".cache/a.jpg", false},
+
+		// ** spans any number of directories, none included
+		{"**/*.jpg", "a.jpg", true},
+		{"**/*.jpg", "x/y/z/a.jpg", true},
+		{"photos/**/*.jpg", "photos/a.jpg", true},
+		{"photos/**/*.jpg", "photos/2024/05/a.jpg", true},
+		{"photos/**/*.jpg", "videos/2024/a.jpg", false},
+		{"photos/**", "photos/2024/a.jpg", true},
+		{"photos/**/**/a.jpg", "photos/x/a.jpg", true},
+		{"**/raw/*.cr2", "2024/raw/a.cr2", true},
+		{"**/raw/*.cr2", "2024/raw/x/a.cr2", false},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/z/c", false},
+		{"**/.git/*", "src/.git/config", true},
+		{"**/*/config", "src/.git/config", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.glob, path.Base(tt.rel), tt.rel); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.glob, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestValidGlob(t *testing.T) {
+	for glob, valid := range map[string]bool{
+		"*.jpg":       true,
+		"photos/**/*": true,
+		"[a-z]*.txt":  true,
+		"[a-z.txt":    false,
+		"photos/[/x":  false,
+		`photos/a\`:   false,
+	} {
+		if err := validGlob(glob); (err == nil) != valid {
+			t.Errorf("validGlob(%q) = %v, want valid %v", glob, err, valid)
+		}
+	}
+}
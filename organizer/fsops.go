@@ -0,0 +1,269 @@
+package organizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// perform carries out a single planned operation. It reports whether
+// the file had to be copied to another filesystem rather than renamed.
+func perform(op, source, dest string) (bool, error) {
+	switch op {
+	case OpCopy:
+		return false, copyFile(source, dest)
+	case OpHardlink:
+		return false, os.Link(source, dest)
+	case OpSymlink:
+		// Links are absolute so they resolve wherever dest is
+		target, err := filepath.Abs(source)
+		if err != nil {
+			return false, err
+		}
+		return false, os.Symlink(target, dest)
+	case OpTrash:
+		return trashFile(source, dest)
+	case "", OpMove:
+		return moveFile(source, dest, false)
+	}
+	return false, fmt.Errorf("unknown operation %q", op)
+}
+
+// moveFile renames source to dest, failing with ErrDestExists if dest
+// exists unless replace is set. The check and the rename are one atomic
+// step, so concurrent runs cannot clobber each other's files. When source
+// and dest are on different filesystems it falls back to copying source,
+// verifying the copy and only then removing source, and reports that it
+// did so.
+func moveFile(source, dest string, replace bool) (bool, error) {
+	rename := renameNoReplace
+	if replace {
+		rename = os.Rename
+	}
+	crossDevice := false
+	err := rename(source, dest)
+	if err != nil && isCrossDevice(err) {
+		crossDevice = true
+		err = moveAcross(source, dest, replace)
+	}
+	if errors.Is(err, os.ErrExist) {
+		err = ErrDestExists
+	}
+	return crossDevice, err
+}
+
+// linkRename renames source to dest without replacing an existing dest,
+// by hard-linking dest first. On filesystems without hard links it checks
+// that dest is free before renaming, which is not atomic.
+func linkRename(source, dest string) error {
+	// Hard links to symbolic links are not portable
+	if info, err := os.Lstat(source); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		err := os.Link(source, dest)
+		if err == nil {
+			if err := os.Remove(source); err != nil {
+				os.Remove(dest)
+				return err
+			}
+			return nil
+		}
+		if errors.Is(err, os.ErrExist) || isCrossDevice(err) {
+			return err
+		}
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return &os.LinkError{Op: "rename", Old: source, New: dest, Err: os.ErrExist}
+	}
+	return os.Rename(source, dest)
+}
+
+// moveAcross moves source to dest on another filesystem. Regular files
+// are copied with their metadata and verified by size and SHA-256 before
+// source is removed; symbolic links are recreated. An existing dest is
+// only replaced if replace is set.
+func moveAcross(source, dest string, replace bool) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if replace {
+		if err := os.Remove(dest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(target, dest); err != nil {
+			return err
+		}
+		preserveOwner(dest, info)
+	case info.Mode().IsRegular():
+		sum, err := copyHashed(source, dest)
+		if err != nil {
+			return err
+		}
+		if err := verifyCopy(dest, info.Size(), sum); err != nil {
+			os.Remove(dest)
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot move %s to another filesystem: not a regular file", source)
+	}
+
+	if err := os.Remove(source); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return nil
+}
+
+// verifyCopy checks that the file at path has the given size and SHA-256
+func verifyCopy(path string, size int64, sum []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if n != size || !bytes.Equal(h.Sum(nil), sum) {
+		return fmt.Errorf("copy of %s does not match the original", path)
+	}
+	return nil
+}
+
+// sameContent reports whether the files at a and b hold the same bytes
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	ia, err := fa.Stat()
+	if err != nil {
+		return false, err
+	}
+	ib, err := fb.Stat()
+	if err != nil {
+		return false, err
+	}
+	if ia.Size() != ib.Size() {
+		return false, nil
+	}
+
+	bufA, bufB := make([]byte, 64<<10), make([]byte, 64<<10)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		eofA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		eofB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		switch {
+		case errA != nil && !eofA:
+			return false, errA
+		case errB != nil && !eofB:
+			return false, errB
+		case eofA || eofB:
+			return eofA && eofB, nil
+		}
+	}
+}
+
+// copyFile copies source to dest, which must not exist yet, keeping the
+// mode, modification time and, where possible, owner and extended
+// attributes of source
+func copyFile(source, dest string) error {
+	_, err := copyHashed(source, dest)
+	return err
+}
+
+// copyHashed is copyFile, returning the SHA-256 of the data copied
+func copyHashed(source, dest string) ([]byte, error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return nil, err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dest)
+		return nil, err
+	}
+
+	// Owner first, as changing it may clear the setuid and setgid bits
+	preserveOwner(dest, info)
+	if err := os.Chmod(dest, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		os.Remove(dest)
+		return nil, err
+	}
+	copyXattrs(source, dest)
+	if err := os.Chtimes(dest, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(dest)
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// mkdirAll creates dir and any missing parents like os.MkdirAll and
+// returns the directories it created, parents first
+func mkdirAll(dir string) ([]string, error) {
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(missing)-1; i < j; i, j = i+1, j-1 {
+		missing[i], missing[j] = missing[j], missing[i]
+	}
+	return missing, nil
+}
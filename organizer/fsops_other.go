@@ -0,0 +1,25 @@
+//go:build !unix
+
+package organizer
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// isCrossDevice reports whether a rename failed because source and
+// destination are on different volumes
+func isCrossDevice(err error) bool {
+	// ERROR_NOT_SAME_DEVICE
+	return runtime.GOOS == "windows" && errors.Is(err, syscall.Errno(17))
+}
+
+// fileID reports false where device and inode numbers are not available
+func fileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
+
+// preserveOwner does nothing where files have no Unix owner
+func preserveOwner(path string, info os.FileInfo) {}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package organizer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// isCrossDevice reports whether a rename failed because source and
+// destination are on different filesystems
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+// fileID returns the device the file described by info is on and its
+// inode number
+func fileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino), true
+	}
+	return 0, 0, false
+}
+
+// preserveOwner gives path the owner and group in info. Failures, e.g.
+// when not running as root, are ignored.
+func preserveOwner(path string, info os.FileInfo) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(path, int(st.Uid), int(st.Gid))
+	}
+}
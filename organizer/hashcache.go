@@ -0,0 +1,307 @@
+package organizer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// HashCacheName is the file in CacheDir that file digests are kept in
+const HashCacheName = "hashes.jsonl"
+
+// Kinds of digests kept in the hash cache. The sample kind names the
+// sample size, so a different size does not reuse old digests.
+const (
+	hashKindSample = "sample16k"
+	hashKindFull   = "full"
+)
+
+// CacheDir returns the directory gorder caches data in:
+// $XDG_CACHE_HOME/gorder, or gorder in the user cache directory
+func CacheDir() (string, error) {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		var err error
+		if cacheHome, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(cacheHome, "gorder"), nil
+}
+
+// HashCache remembers the digests of files, so that files which have not
+// changed since they were hashed are not read again. A file is taken to
+// be unchanged while its absolute path, device, inode, size and
+// modification time are. It is safe for concurrent use.
+type HashCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[hashCacheKey]*hashCacheEntry
+	// added holds the entries not saved yet
+	added map[hashCacheKey]*hashCacheEntry
+}
+
+// hashCacheKey identifies a digest in the cache
+type hashCacheKey struct {
+	path, algorithm, kind string
+}
+
+// hashCacheEntry is one line of the cache file
+type hashCacheEntry struct {
+	V         int    `json:"v"`
+	Path      string `json:"path"`
+	Algorithm string `json:"algo"`
+	Kind      string `json:"kind"`
+	Dev       uint64 `json:"dev,omitempty"`
+	Ino       uint64 `json:"ino,omitempty"`
+	Size      int64  `json:"size"`
+	MtimeNs   int64  `json:"mtime_ns"`
+	Digest    string `json:"digest"`
+}
+
+func (e *hashCacheEntry) key() hashCacheKey {
+	return hashCacheKey{e.Path, e.Algorithm, e.Kind}
+}
+
+// matches reports whether the entry was recorded for file as it is now
+func (e *hashCacheEntry) matches(file DuplicateFile) bool {
+	if e.Size != file.Size || e.MtimeNs != file.mtime {
+		return false
+	}
+	// Device and inode numbers are not known everywhere
+	if e.Ino != 0 && file.ino != 0 && (e.Dev != file.dev || e.Ino != file.ino) {
+		return false
+	}
+	return true
+}
+
+// OpenHashCache loads the hash cache at path, or HashCacheName in
+// CacheDir when path is empty. A missing cache is empty.
+func OpenHashCache(path string) (*HashCache, error) {
+	if path == "" {
+		dir, err := CacheDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, HashCacheName)
+	}
+	entries, err := readHashCache(path)
+	if err != nil {
+		return nil, err
+	}
+	return &HashCache{
+		path:    path,
+		entries: entries,
+		added:   make(map[hashCacheKey]*hashCacheEntry),
+	}, nil
+}
+
+// readHashCache reads the entries of the cache file at path. Lines that
+// cannot be parsed, e.g. from another version, are skipped.
+func readHashCache(path string) (map[hashCacheKey]*hashCacheEntry, error) {
+	entries := make(map[hashCacheKey]*hashCacheEntry)
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e hashCacheEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.V != 1 || e.Digest == "" {
+			continue
+		}
+		entries[e.key()] = &e
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading hash cache %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Path returns the file the cache is kept in
+func (c *HashCache) Path() string {
+	return c.path
+}
+
+// lookup returns the digest of kind recorded for file with algorithm, if
+// the file has not changed since
+func (c *HashCache) lookup(file DuplicateFile, algorithm, kind string) (string, bool) {
+	path, err := filepath.Abs(file.Path)
+	if err != nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[hashCacheKey{path, algorithm, kind}]
+	if !ok || !e.matches(file) {
+		return "", false
+	}
+	return e.Digest, true
+}
+
+// store records the digest of kind of file computed with algorithm
+func (c *HashCache) store(file DuplicateFile, algorithm, kind, digest string) {
+	path, err := filepath.Abs(file.Path)
+	if err != nil {
+		return
+	}
+	e := &hashCacheEntry{
+		V:         1,
+		Path:      path,
+		Algorithm: algorithm,
+		Kind:      kind,
+		Dev:       file.dev,
+		Ino:       file.ino,
+		Size:      file.Size,
+		MtimeNs:   file.mtime,
+		Digest:    digest,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[e.key()] = e
+	c.added[e.key()] = e
+}
+
+// Save writes the entries recorded since the cache was opened or last
+// saved to the cache file. The file is locked while it is rewritten, and
+// entries other runs saved in the meantime are kept.
+func (c *HashCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.added) == 0 {
+		return nil
+	}
+	return c.update(func(entries map[hashCacheKey]*hashCacheEntry) {
+		for k, e := range c.added {
+			entries[k] = e
+		}
+	})
+}
+
+// update locks the cache file, applies edit to the entries currently in
+// it and replaces it atomically. c.mu must be held.
+func (c *HashCache) update(edit func(map[hashCacheKey]*hashCacheEntry)) error {
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(c.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("locking hash cache: %w", err)
+	}
+
+	entries, err := readHashCache(c.path)
+	if err != nil {
+		return err
+	}
+	edit(entries)
+
+	tmp, err := os.CreateTemp(dir, ".hashes-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	keys := make([]hashCacheKey, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.algorithm != b.algorithm {
+			return a.algorithm < b.algorithm
+		}
+		return a.kind < b.kind
+	})
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, k := range keys {
+		if err := enc.Encode(entries[k]); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+
+	c.entries = entries
+	c.added = make(map[hashCacheKey]*hashCacheEntry)
+	return nil
+}
+
+// HashCacheStats describes the content of a hash cache
+type HashCacheStats struct {
+	Path string
+	// Size is the size of the cache file
+	Size int64
+	// Entries is the number of digests, Files the number of files they
+	// belong to
+	Entries int
+	Files   int
+	// Algorithms counts the digests per hash algorithm
+	Algorithms map[string]int
+}
+
+// Stats describes the cache
+func (c *HashCache) Stats() HashCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := HashCacheStats{Path: c.path, Entries: len(c.entries), Algorithms: make(map[string]int)}
+	if info, err := os.Stat(c.path); err == nil {
+		s.Size = info.Size()
+	}
+	files := make(map[string]bool)
+	for k := range c.entries {
+		files[k.path] = true
+		s.Algorithms[k.algorithm]++
+	}
+	s.Files = len(files)
+	return s
+}
+
+// Prune drops the digests of files that no longer exist or have changed
+// since they were hashed. It returns the number of digests dropped.
+func (c *HashCache) Prune() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pruned := 0
+	err := c.update(func(entries map[hashCacheKey]*hashCacheEntry) {
+		for k, e := range entries {
+			info, err := os.Lstat(e.Path)
+			if err == nil && info.Mode().IsRegular() && e.matches(duplicateFile(e.Path, info)) {
+				continue
+			}
+			delete(entries, k)
+			pruned++
+		}
+	})
+	return pruned, err
+}
@@ -0,0 +1,193 @@
+package organizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cachedFile writes a file and describes it as FindDuplicates does
+func cachedFile(t *testing.T, path, content string) DuplicateFile {
+	t.Helper()
+	return duplicateFile(path, writeFile(t, path, content))
+}
+
+func TestHashCacheSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache", HashCacheName)
+	a := cachedFile(t, filepath.Join(dir, "a.txt"), "aaaa")
+	b := cachedFile(t, filepath.Join(dir, "b.txt"), "bbbb")
+
+	c, err := OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.store(a, HashSHA256, hashKindFull, "digest-a")
+	c.store(a, HashSHA256, hashKindSample, "sample-a")
+	c.store(b, HashXXH64, hashKindFull, "digest-b")
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file            DuplicateFile
+		algorithm, kind string
+		want            string
+	}{
+		{a, HashSHA256, hashKindFull, "digest-a"},
+		{a, HashSHA256, hashKindSample, "sample-a"},
+		{b, HashXXH64, hashKindFull, "digest-b"},
+		// Digests are kept per algorithm and kind
+		{b, HashSHA256, hashKindFull, ""},
+		{b, HashXXH64, hashKindSample, ""},
+	}
+	for _, tt := range tests {
+		got, ok := c.lookup(tt.file, tt.algorithm, tt.kind)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("lookup(%s, %s, %s) = %q, %v, want %q", tt.file.Path, tt.algorithm, tt.kind, got, ok, tt.want)
+		}
+	}
+	s := c.Stats()
+	if s.Entries != 3 || s.Files != 2 || s.Algorithms[HashSHA256] != 2 || s.Algorithms[HashXXH64] != 1 || s.Size == 0 {
+		t.Errorf("Stats() = %+v, want 3 entries of 2 files", s)
+	}
+
+	// A file changed since it was hashed is hashed again
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(a.Path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.lookup(duplicateFile(a.Path, info), HashSHA256, hashKindFull); ok {
+		t.Errorf("lookup of a touched file = %q, want a miss", got)
+	}
+	grown := cachedFile(t, b.Path, "bbbbb")
+	if got, ok := c.lookup(grown, HashXXH64, hashKindFull); ok {
+		t.Errorf("lookup of a grown file = %q, want a miss", got)
+	}
+}
+
+func TestHashCacheSkipsBadLines(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, HashCacheName)
+	a := cachedFile(t, filepath.Join(dir, "a.txt"), "aaaa")
+	line := func(v int, digest string) string {
+		return fmt.Sprintf(`{"v":%d,"path":%q,"algo":"sha256","kind":"full","size":%d,"mtime_ns":%d,"digest":%q}`,
+			v, a.Path, a.Size, a.mtime, digest)
+	}
+	content := "not json\n" +
+		line(2, "from-a-later-version") + "\n" +
+		line(1, "") + "\n" +
+		"\n" +
+		line(1, "good") + "\n" +
+		`{"v":1,"path":"/truncated`
+	writeFile(t, cachePath, content)
+
+	c, err := OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.lookup(a, HashSHA256, hashKindFull); got != "good" || !ok {
+		t.Errorf("lookup = %q, %v, want the valid line's digest", got, ok)
+	}
+	if s := c.Stats(); s.Entries != 1 {
+		t.Errorf("cache holds %d entries, want 1", s.Entries)
+	}
+
+	missing, err := OpenHashCache(filepath.Join(dir, "missing", HashCacheName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := missing.Stats(); s.Entries != 0 {
+		t.Errorf("missing cache holds %d entries, want 0", s.Entries)
+	}
+}
+
+func TestHashCacheConcurrentSaves(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, HashCacheName)
+
+	// Runs that opened the cache before any of them saved keep each
+	// other's digests
+	const runs = 8
+	caches := make([]*HashCache, runs)
+	for i := range caches {
+		var err error
+		if caches[i], err = OpenHashCache(cachePath); err != nil {
+			t.Fatal(err)
+		}
+		file := cachedFile(t, filepath.Join(dir, fmt.Sprintf("%d.txt", i)), fmt.Sprint(i))
+		caches[i].store(file, HashSHA256, hashKindFull, fmt.Sprint("digest-", i))
+	}
+	var wg sync.WaitGroup
+	for _, c := range caches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Save(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c, err := OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Entries != runs {
+		t.Errorf("cache holds %d entries, want %d", s.Entries, runs)
+	}
+}
+
+func TestHashCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, HashCacheName)
+	kept := cachedFile(t, filepath.Join(dir, "kept.txt"), "kept")
+	gone := cachedFile(t, filepath.Join(dir, "gone.txt"), "gone")
+	changed := cachedFile(t, filepath.Join(dir, "changed.txt"), "changed")
+
+	c, err := OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []DuplicateFile{kept, gone, changed} {
+		c.store(file, HashSHA256, hashKindFull, "digest")
+		c.store(file, HashSHA256, hashKindSample, "sample")
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(gone.Path); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, changed.Path, "changed, and longer")
+	pruned, err := c.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 4 {
+		t.Errorf("Prune dropped %d digests, want 4", pruned)
+	}
+
+	if c, err = OpenHashCache(cachePath); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Entries != 2 || s.Files != 1 {
+		t.Errorf("cache holds %d entries of %d files after pruning, want 2 of 1", s.Entries, s.Files)
+	}
+	if _, ok := c.lookup(kept, HashSHA256, hashKindFull); !ok {
+		t.Error("the digest of an unchanged file was pruned")
+	}
+}
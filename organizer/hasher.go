@@ -0,0 +1,87 @@
+package organizer
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Hash algorithms FindDuplicates can compare files with
+const (
+	HashSHA256  = "sha256"
+	HashSHA1    = "sha1"
+	HashMD5     = "md5"
+	HashBLAKE2b = "blake2b"
+	HashXXH64   = "xxh64"
+	HashCRC32C  = "crc32c"
+)
+
+// Hasher is a hash algorithm used to tell files apart
+type Hasher interface {
+	// Name identifies the algorithm in reports, e.g. "sha256"
+	Name() string
+	// New returns a new hash of the algorithm
+	New() hash.Hash
+}
+
+// hasher is a Hasher backed by a hash constructor
+type hasher struct {
+	name string
+	new  func() hash.Hash
+}
+
+// Name returns the name of the algorithm
+func (h hasher) Name() string {
+	return h.name
+}
+
+// New returns a new hash of the algorithm
+func (h hasher) New() hash.Hash {
+	return h.new()
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// hashers lists the built-in algorithms
+var hashers = []Hasher{
+	hasher{HashSHA256, sha256.New},
+	hasher{HashSHA1, sha1.New},
+	hasher{HashMD5, md5.New},
+	hasher{HashBLAKE2b, func() hash.Hash {
+		// Only fails for keys longer than 64 bytes
+		h, _ := blake2b.New256(nil)
+		return h
+	}},
+	hasher{HashXXH64, func() hash.Hash { return xxhash.New() }},
+	hasher{HashCRC32C, func() hash.Hash { return crc32.New(crc32cTable) }},
+}
+
+// NewHasher returns the built-in hash algorithm called name, SHA-256 when
+// name is empty
+func NewHasher(name string) (Hasher, error) {
+	if name == "" {
+		name = HashSHA256
+	}
+	for _, h := range hashers {
+		if strings.EqualFold(h.Name(), name) {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown hash algorithm %q (want %s)", name, strings.Join(HashAlgorithms(), ", "))
+}
+
+// HashAlgorithms returns the names of the built-in hash algorithms
+func HashAlgorithms() []string {
+	names := make([]string, len(hashers))
+	for i, h := range hashers {
+		names[i] = h.Name()
+	}
+	return names
+}
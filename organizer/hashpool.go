@@ -0,0 +1,85 @@
+package organizer
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// hashPool hashes files with a bounded number of workers, and fewer on
+// devices that do not cope with parallel reads
+type hashPool struct {
+	// jobs is the number of files hashed at once
+	jobs int
+	// deviceJobs is the number of files hashed at once on one device, or
+	// zero to allow one on spinning disks and jobs on others
+	deviceJobs int
+}
+
+// newHashPool returns a pool of jobs workers, runtime.NumCPU() when jobs
+// is not positive
+func newHashPool(jobs, deviceJobs int) *hashPool {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	return &hashPool{jobs: jobs, deviceJobs: deviceJobs}
+}
+
+// hashAll hashes files, returning their hashes and errors in the order of
+// files whatever order the workers finish in
+func (p *hashPool) hashAll(ctx context.Context, files []DuplicateFile, hash func(DuplicateFile) (string, error)) ([]string, []error, error) {
+	hashes := make([]string, len(files))
+	errs := make([]error, len(files))
+
+	// One queue per device, served by as many workers as the device
+	// allows. Workers only take one of the jobs slots to hash, so those
+	// waiting on a busy device never keep other devices from being read.
+	count := make(map[uint64]int)
+	for _, file := range files {
+		count[file.dev]++
+	}
+	queues := make(map[uint64]chan int, len(count))
+	for dev, n := range count {
+		queues[dev] = make(chan int, n)
+	}
+	for i, file := range files {
+		queues[file.dev] <- i
+	}
+
+	slots := make(chan struct{}, p.jobs)
+	var wg sync.WaitGroup
+	for dev, queue := range queues {
+		close(queue)
+		for w := 0; w < min(p.deviceLimit(dev), count[dev]); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range queue {
+					select {
+					case slots <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+					hashes[i], errs[i] = hash(files[i])
+					<-slots
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return hashes, errs, nil
+}
+
+// deviceLimit returns the number of files hashed at once on device dev
+func (p *hashPool) deviceLimit(dev uint64) int {
+	if p.deviceJobs > 0 {
+		return p.deviceJobs
+	}
+	if isRotational(dev) {
+		return 1
+	}
+	return p.jobs
+}
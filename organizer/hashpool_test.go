@@ -0,0 +1,70 @@
+package organizer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHashAllKeepsOtherDevicesBusy(t *testing.T) {
+	files := []DuplicateFile{
+		{Path: "slow1", dev: 1},
+		{Path: "slow2", dev: 1},
+		{Path: "fast1", dev: 2},
+		{Path: "fast2", dev: 2},
+	}
+	release := make(chan struct{})
+	fast := make(chan string, 2)
+	hash := func(file DuplicateFile) (string, error) {
+		if file.dev == 1 {
+			<-release
+		} else {
+			fast <- file.Path
+		}
+		return file.Path, nil
+	}
+
+	type result struct {
+		hashes []string
+		err    error
+	}
+	done := make(chan result)
+	go func() {
+		hashes, _, err := newHashPool(2, 1).hashAll(context.Background(), files, hash)
+		done <- result{hashes, err}
+	}()
+
+	// Device 1 takes one file at a time and that file hangs: device 2 must
+	// still get the second job
+	for range 2 {
+		select {
+		case <-fast:
+		case <-time.After(5 * time.Second):
+			close(release)
+			t.Fatal("files on device 2 were not hashed while device 1 was busy")
+		}
+	}
+	close(release)
+
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	for i, file := range files {
+		if res.hashes[i] != file.Path {
+			t.Errorf("hash of %s = %q, want %q", file.Path, res.hashes[i], file.Path)
+		}
+	}
+}
+
+func TestHashAllStopsWhenCancelled(t *testing.T) {
+	files := []DuplicateFile{{Path: "a", dev: 1}, {Path: "b", dev: 1}, {Path: "c", dev: 2}}
+	ctx, cancel := context.WithCancel(context.Background())
+	hash := func(file DuplicateFile) (string, error) {
+		cancel()
+		return file.Path, nil
+	}
+	if _, _, err := newHashPool(1, 1).hashAll(ctx, files, hash); err != context.Canceled {
+		t.Errorf("hashAll returned %v, want %v", err, context.Canceled)
+	}
+}
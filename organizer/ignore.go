@@ -0,0 +1,227 @@
+package organizer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreName is the file listing, in gitignore syntax, the files and
+// directories gorder leaves alone. It is honored in every directory.
+const IgnoreName = ".gorderignore"
+
+// defaultIgnore holds the directories that are never descended into
+// unless a .gorderignore re-includes them with a negated pattern
+var defaultIgnore = []string{".git/", ".hg/", ".svn/"}
+
+// ignoreRule is a single gitignore pattern
+type ignoreRule struct {
+	// base is the slash-separated directory of the ignore file relative
+	// to the walked root, "" for the root itself
+	base     string
+	globs    []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreLine parses a line of an ignore file. It returns false for
+// blank lines, comments and invalid patterns.
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	r := ignoreRule{base: base}
+
+	// Trailing spaces are ignored unless escaped
+	line = strings.TrimRight(line, " \t\r")
+	if strings.HasSuffix(line, "\\") {
+		line += " "
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return r, false
+	}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\#") || strings.HasPrefix(line, "\\!") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// A slash at the start or in the middle anchors the pattern to the
+	// directory of the ignore file
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return r, false
+	}
+	r.globs = strings.Split(line, "/")
+	for _, glob := range r.globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return r, false
+		}
+	}
+	return r, true
+}
+
+// match reports whether the rule matches the slash-separated path rel,
+// relative to the walked root
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if !r.anchored {
+		return matchPart(r.globs[0], path.Base(rel))
+	}
+	return matchParts(r.globs, strings.Split(rel, "/"), matchPart)
+}
+
+// matchPart matches a gitignore glob against a single path element.
+// Unlike include and exclude globs, wildcards match a leading dot.
+func matchPart(glob, part string) bool {
+	ok, _ := path.Match(glob, part)
+	return ok
+}
+
+// ignorer decides which paths of a directory tree are pruned
+type ignorer struct {
+	root    string
+	exclude []ignoreRule
+	// rules holds the defaults followed by the patterns of every ignore
+	// file read so far, shallower files first
+	rules []ignoreRule
+}
+
+// newIgnorer returns an ignorer for the tree at root. excludeDirs are
+// globs matching directory names, or paths relative to root when they
+// contain a slash.
+func newIgnorer(root string, excludeDirs []string) (*ignorer, error) {
+	ig := &ignorer{root: root}
+	for _, line := range defaultIgnore {
+		r, _ := parseIgnoreLine(line, "")
+		ig.rules = append(ig.rules, r)
+	}
+	for _, dir := range excludeDirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		if strings.HasPrefix(dir, "!") {
+			return nil, fmt.Errorf("exclude-dir %q: negated patterns belong in %s", dir, IgnoreName)
+		}
+		if err := validGlob(dir); err != nil {
+			return nil, fmt.Errorf("exclude-dir: %w", err)
+		}
+		r, ok := parseIgnoreLine(strings.TrimRight(dir, "/")+"/", "")
+		if !ok {
+			return nil, fmt.Errorf("exclude-dir: invalid pattern %q", dir)
+		}
+		ig.exclude = append(ig.exclude, r)
+	}
+	return ig, nil
+}
+
+// load reads the ignore file of dir, if any
+func (ig *ignorer) load(dir string) error {
+	f, err := os.Open(filepath.Join(dir, IgnoreName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	base := ig.rel(dir)
+	if base == "." {
+		base = ""
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			ig.rules = append(ig.rules, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading %s: %w", f.Name(), err)
+	}
+	return nil
+}
+
+// ignored reports whether the file or directory at p is pruned. The last
+// matching pattern wins, so deeper ignore files override shallower ones.
+func (ig *ignorer) ignored(p string, isDir bool) bool {
+	rel := ig.rel(p)
+	if rel == "." {
+		return false
+	}
+	for _, r := range ig.exclude {
+		if r.match(rel, isDir) {
+			return true
+		}
+	}
+	ignored := false
+	for _, r := range ig.rules {
+		if r.match(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (ig *ignorer) rel(p string) string {
+	rel, err := filepath.Rel(ig.root, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// walkTree walks the tree at root like filepath.Walk, except that fn is
+// not called for root, directories excluded by excludeDirs or ignore
+// files are pruned with their whole subtree, and ignored files are
+// skipped. fn may return filepath.SkipDir to prune a directory.
+func walkTree(ctx context.Context, root string, excludeDirs []string, fn func(path string, info os.FileInfo) error) error {
+	ig, err := newIgnorer(root, excludeDirs)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ig.ignored(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			// Filepath.Walk visits a directory before its contents, so its
+			// ignore file is loaded in time
+			if err := ig.load(path); err != nil {
+				return err
+			}
+		}
+		if path == root {
+			return nil
+		}
+		return fn(path, info)
+	})
+}
@@ -0,0 +1,145 @@
+package organizer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want ignoreRule
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "# comment", ok: false},
+		{line: "/", ok: false},
+		{line: "!", ok: false},
+		{line: "[a-", ok: false},
+		{line: "*.tmp", ok: true, want: ignoreRule{globs: []string{"*.tmp"}}},
+		{line: "*.tmp  ", ok: true, want: ignoreRule{globs: []string{"*.tmp"}}},
+		{line: `a\ `, ok: true, want: ignoreRule{globs: []string{`a\ `}}},
+		{line: `\#notes`, ok: true, want: ignoreRule{globs: []string{"#notes"}}},
+		{line: `\!keep`, ok: true, want: ignoreRule{globs: []string{"!keep"}}},
+		{line: "!*.log", ok: true, want: ignoreRule{globs: []string{"*.log"}, negate: true}},
+		{line: "build/", ok: true, want: ignoreRule{globs: []string{"build"}, dirOnly: true}},
+		{line: "/build", ok: true, want: ignoreRule{globs: []string{"build"}, anchored: true}},
+		{line: "docs/*.md", ok: true, want: ignoreRule{globs: []string{"docs", "*.md"}, anchored: true}},
+		{line: "**/cache/", ok: true, want: ignoreRule{globs: []string{"**", "cache"}, dirOnly: true, anchored: true}},
+	}
+	for _, tt := range tests {
+		got, ok := parseIgnoreLine(tt.line, "")
+		if ok != tt.ok {
+			t.Errorf("parseIgnoreLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !slices.Equal(got.globs, tt.want.globs) || got.negate != tt.want.negate ||
+			got.dirOnly != tt.want.dirOnly || got.anchored != tt.want.anchored {
+			t.Errorf("parseIgnoreLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	tests := []struct {
+		line, base, rel string
+		isDir           bool
+		want            bool
+	}{
+		{line: "*.tmp", rel: "a.tmp", want: true},
+		{line: "*.tmp", rel: "x/y/a.tmp", want: true},
+		{line: "*", rel: ".hidden", want: true},
+		{line: "build/", rel: "src/build", isDir: true, want: true},
+		{line: "build/", rel: "src/build", want: false},
+		{line: "/build", rel: "build", isDir: true, want: true},
+		{line: "/build", rel: "src/build", isDir: true, want: false},
+		{line: "docs/*.md", rel: "docs/a.md", want: true},
+		{line: "docs/*.md", rel: "docs/x/a.md", want: false},
+		{line: "**/cache", rel: "cache", isDir: true, want: true},
+		{line: "**/cache", rel: "a/b/cache", isDir: true, want: true},
+		{line: "a/**/z", rel: "a/z", want: true},
+		{line: "a/**/z", rel: "a/b/c/z", want: true},
+		{line: "a/**/z", rel: "b/a/z", want: false},
+		{line: "a/**", rel: "a/b/c", want: true},
+		{line: "*.tmp", base: "sub", rel: "sub/x/a.tmp", want: true},
+		{line: "*.tmp", base: "sub", rel: "other/a.tmp", want: false},
+		{line: "/a.tmp", base: "sub", rel: "sub/a.tmp", want: true},
+		{line: "/a.tmp", base: "sub", rel: "sub/x/a.tmp", want: false},
+		{line: "*.tmp", base: "sub", rel: "subway/a.tmp", want: false},
+	}
+	for _, tt := range tests {
+		r, ok := parseIgnoreLine(tt.line, tt.base)
+		if !ok {
+			t.Fatalf("parseIgnoreLine(%q) failed", tt.line)
+		}
+		if got := r.match(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("%q in %q: match(%q) = %v, want %v", tt.line, tt.base, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestWalkTreeHonorsIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		IgnoreName:              "*.log\n!keep.log\nbuild/\n",
+		"a.log":                 "",
+		"keep.log":              "",
+		"a.txt":                 "",
+		"build/out.txt":         "",
+		"sub/" + IgnoreName:     "!*.log\n/local.txt\n",
+		"sub/b.log":             "",
+		"sub/local.txt":         "",
+		"sub/deep/local.txt":    "",
+		"node_modules/x.js":     "",
+		".git/config":           "",
+		"vendor/" + IgnoreName:  "!.git/\n",
+		"vendor/.git/HEAD":      "",
+		"vendor/lib/keep.go":    "",
+		"vendor/lib/.gitignore": "",
+	} {
+		writeFile(t, filepath.Join(dir, path), content)
+	}
+
+	var got []string
+	err := walkTree(context.Background(), dir, []string{"node_modules"}, func(path string, info os.FileInfo) error {
+		if !info.IsDir() && filepath.Base(path) != IgnoreName {
+			rel, _ := filepath.Rel(dir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"a.txt",
+		"keep.log",
+		"sub/b.log",
+		"sub/deep/local.txt",
+		"vendor/.git/HEAD",
+		"vendor/lib/.gitignore",
+		"vendor/lib/keep.go",
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("walked\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestNewIgnorerRejectsNegatedExcludeDir(t *testing.T) {
+	if _, err := newIgnorer(".", []string{"!keep"}); err == nil {
+		t.Error("newIgnorer accepted a negated exclude-dir")
+	}
+	if _, err := newIgnorer(".", []string{"[bad"}); err == nil {
+		t.Error("newIgnorer accepted an invalid exclude-dir")
+	}
+}
@@ -0,0 +1,330 @@
+package organizer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JournalName is the undo journal kept in the organized directory. It
+// is appended to by every operation so earlier ones can still be undone.
+const JournalName = ".gorder_journal.jsonl"
+
+// JournalVersion is the format version written to every journal line
+const JournalVersion = 1
+
+// Operation kinds
+const (
+	KindOrganize = "organize"
+	KindFetch    = "fetch"
+	KindUndo     = "undo"
+	KindRedo     = "redo"
+)
+
+// Journal record types
+const (
+	recordOperation = "operation"
+	recordFolder    = "folder"
+	recordMove      = "move"
+	recordRemoved   = "rmdir"
+)
+
+// journalRecord is a single line of the journal. An operation line is
+// followed by the folders it created and the moves it performed, each
+// tagged with the operation ID.
+type journalRecord struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+
+	// Operation lines
+	Kind    string    `json:"kind,omitempty"`
+	Time    time.Time `json:"time,omitzero"`
+	Command []string  `json:"command,omitempty"`
+	WorkDir string    `json:"workdir,omitempty"`
+	Reverts string    `json:"reverts,omitempty"`
+
+	// Folder and removed directory lines
+	Path string      `json:"path,omitempty"`
+	Mode os.FileMode `json:"mode,omitempty"`
+
+	// Move lines
+	Source  string    `json:"source,omitempty"`
+	Dest    string    `json:"dest,omitempty"`
+	Op      string    `json:"op,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mtime,omitzero"`
+	// CrossDevice marks moves between filesystems, performed by copying
+	// and removing the original
+	CrossDevice bool `json:"cross_device,omitempty"`
+}
+
+// Operation is a single run of gorder recorded in the journal
+type Operation struct {
+	ID      string
+	Kind    string
+	Time    time.Time
+	Command []string
+	WorkDir string
+	// Reverts is the ID of the operation an undo reverted, or of the undo
+	// a redo reverted
+	Reverts string
+	// RevertedBy is the ID of the undo or redo that reverted this
+	// operation, if any
+	RevertedBy string
+	// Folders lists the folders the operation created, parents first
+	Folders []string
+	// Moves lists the moves in the order they were performed. For an undo
+	// they are the moves of the reverted operation that were reversed.
+	Moves []Move
+	// Removed lists the directories the operation removed, deepest first
+	Removed []RemovedDir
+
+	// reverted holds the destinations of the moves undos reverted
+	reverted map[string]bool
+}
+
+// RemovedDir is a directory removed by an operation, with the mode and
+// modification time it is recreated with on undo
+type RemovedDir struct {
+	Path    string
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// Undone reports whether op was reverted: an operation whose moves were
+// all undone, or an undo that was redone
+func (op *Operation) Undone() bool {
+	if op.RevertedBy == "" {
+		return false
+	}
+	return op.Kind == KindUndo || len(op.Pending()) == 0
+}
+
+// Pending returns the moves of op that no undo has reverted yet
+func (op *Operation) Pending() []Move {
+	var moves []Move
+	for _, m := range op.Moves {
+		if !op.reverted[m.Dest] {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// ReadJournal reads the operations recorded in the journal at path,
+// oldest first. A truncated last line, as left by an interrupted run, is
+// ignored.
+func ReadJournal(path string) ([]*Operation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []*Operation
+	byID := make(map[string]*Operation)
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var rec journalRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("journal %s line %d: %w", path, i+1, err)
+		}
+		if rec.Version < 1 || rec.Version > JournalVersion {
+			return nil, fmt.Errorf("journal %s line %d: unsupported version %d", path, i+1, rec.Version)
+		}
+
+		if rec.Type == recordOperation {
+			op := &Operation{
+				ID:      rec.ID,
+				Kind:    rec.Kind,
+				Time:    rec.Time,
+				Command: rec.Command,
+				WorkDir: rec.WorkDir,
+				Reverts: rec.Reverts,
+			}
+			ops = append(ops, op)
+			byID[rec.ID] = op
+			continue
+		}
+		op, ok := byID[rec.ID]
+		if !ok {
+			return nil, fmt.Errorf("journal %s line %d: unknown operation %q", path, i+1, rec.ID)
+		}
+		switch rec.Type {
+		case recordFolder:
+			op.Folders = append(op.Folders, rec.Path)
+		case recordRemoved:
+			op.Removed = append(op.Removed, RemovedDir{Path: rec.Path, Mode: rec.Mode, ModTime: rec.ModTime})
+		case recordMove:
+			op.Moves = append(op.Moves, Move{
+				Source:  rec.Source,
+				Dest:    rec.Dest,
+				Op:      rec.Op,
+				Size:    rec.Size,
+				ModTime: rec.ModTime,
+			})
+		default:
+			return nil, fmt.Errorf("journal %s line %d: unknown record type %q", path, i+1, rec.Type)
+		}
+	}
+
+	for _, op := range ops {
+		target, ok := byID[op.Reverts]
+		if !ok {
+			continue
+		}
+		target.RevertedBy = op.ID
+		if op.Kind == KindUndo {
+			if target.reverted == nil {
+				target.reverted = make(map[string]bool)
+			}
+			for _, m := range op.Moves {
+				target.reverted[m.Dest] = true
+			}
+		}
+	}
+	return ops, nil
+}
+
+// History returns the operations recorded in dir's journal, oldest
+// first, or none if nothing was journaled yet
+func History(dir string) ([]*Operation, error) {
+	ops, err := ReadJournal(filepath.Join(dir, JournalName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return ops, err
+}
+
+// journal appends the records of one operation to a journal file. The
+// operation line is only written with the first folder or move, so runs
+// that change nothing leave no trace.
+type journal struct {
+	f       *os.File
+	w       *bufio.Writer
+	op      journalRecord
+	started bool
+}
+
+// openJournal opens the journal at path for appending a new operation
+func openJournal(path, kind, reverts string) (*journal, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	j := &journal{
+		f: f,
+		w: bufio.NewWriter(f),
+		op: journalRecord{
+			Version: JournalVersion,
+			Type:    recordOperation,
+			ID:      newOperationID(now),
+			Kind:    kind,
+			Time:    now,
+			Command: os.Args,
+			Reverts: reverts,
+		},
+	}
+	if wd, err := os.Getwd(); err == nil {
+		j.op.WorkDir = wd
+	}
+	return j, nil
+}
+
+// newOperationID returns a sortable, practically unique operation ID
+func newOperationID(t time.Time) string {
+	var b [3]byte
+	rand.Read(b[:])
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// folder records a folder created by the operation
+func (j *journal) folder(path string) error {
+	return j.write(journalRecord{Type: recordFolder, Path: path})
+}
+
+// removed records a directory removed by the operation
+func (j *journal) removed(d RemovedDir) error {
+	return j.write(journalRecord{Type: recordRemoved, Path: d.Path, Mode: d.Mode, ModTime: d.ModTime})
+}
+
+// move records a completed move
+func (j *journal) move(m Move, crossDevice bool) error {
+	return j.write(journalRecord{
+		Type:        recordMove,
+		Source:      m.Source,
+		Dest:        m.Dest,
+		Op:          m.Op,
+		Size:        m.Size,
+		ModTime:     m.ModTime,
+		CrossDevice: crossDevice,
+	})
+}
+
+// write appends rec, preceded by the operation line if it is the first
+// record. Every record is flushed right away so an interrupted run still
+// leaves an undoable journal. Writing to a nil journal does nothing.
+func (j *journal) write(rec journalRecord) error {
+	if j == nil {
+		return nil
+	}
+	if !j.started {
+		j.started = true
+		if err := writeRecord(j.w, j.op); err != nil {
+			return err
+		}
+	}
+	rec.Version = JournalVersion
+	rec.ID = j.op.ID
+	if err := writeRecord(j.w, rec); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+func writeRecord(w io.Writer, rec journalRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// finish closes the journal, reporting a failure in res
+func (j *journal) finish(res *Result) {
+	if err := j.close(); err != nil && res != nil {
+		res.Errors = append(res.Errors, fmt.Errorf("could not write journal: %w", err))
+	}
+}
+
+// close syncs and closes the journal
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+	if err := j.w.Flush(); err != nil {
+		j.f.Close()
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		j.f.Close()
+		return err
+	}
+	return j.f.Close()
+}
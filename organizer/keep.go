@@ -0,0 +1,145 @@
+package organizer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Keep policies decide which copy of a group of duplicates is kept
+const (
+	// KeepFirst keeps the copy found first while walking the tree
+	KeepFirst = "first"
+	// KeepOldest keeps the copy modified longest ago
+	KeepOldest = "oldest"
+	// KeepNewest keeps the copy modified last
+	KeepNewest = "newest"
+	// KeepShortestPath keeps the copy with the shortest path
+	KeepShortestPath = "shortest-path"
+	// KeepLongestPath keeps the copy with the longest path
+	KeepLongestPath = "longest-path"
+	// KeepFirstAlpha keeps the copy whose path sorts first
+	KeepFirstAlpha = "first-alpha"
+)
+
+// KeepPolicies returns the names of the keep policies
+func KeepPolicies() []string {
+	return []string{KeepFirst, KeepOldest, KeepNewest, KeepShortestPath, KeepLongestPath, KeepFirstAlpha}
+}
+
+// keeper puts the copy to keep first in each group of duplicates
+type keeper struct {
+	policy string
+	// keepIn and preferDelete are absolute directories
+	keepIn       []string
+	preferDelete []string
+}
+
+// newKeeper checks the keep settings of opts. Relative directories are
+// taken to be below dir.
+func newKeeper(dir string, opts DuplicateOptions) (*keeper, error) {
+	k := &keeper{policy: opts.Keep}
+	if k.policy == "" {
+		k.policy = KeepFirst
+	}
+	valid := false
+	for _, p := range KeepPolicies() {
+		valid = valid || k.policy == p
+	}
+	if !valid {
+		return nil, fmt.Errorf("unknown keep policy %q", opts.Keep)
+	}
+
+	var err error
+	if k.keepIn, err = absDirs(dir, opts.KeepIn); err != nil {
+		return nil, err
+	}
+	if k.preferDelete, err = absDirs(dir, opts.PreferDelete); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// absDirs makes dirs absolute, resolving relative ones against dir
+func absDirs(dir string, dirs []string) ([]string, error) {
+	var abs []string
+	for _, d := range dirs {
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(dir, d)
+		}
+		d, err := filepath.Abs(d)
+		if err != nil {
+			return nil, err
+		}
+		abs = append(abs, d)
+	}
+	return abs, nil
+}
+
+// matchDir returns the index of the deepest directory of dirs that path
+// is in, and its length, or -1 and 0 if it is in none
+func matchDir(path string, dirs []string) (int, int) {
+	best, depth := -1, 0
+	for i, dir := range dirs {
+		if (path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))) && len(dir) > depth {
+			best, depth = i, len(dir)
+		}
+	}
+	return best, depth
+}
+
+// rank orders files by the directories they are in: those in the first
+// keep-in directory come first, those in a prefer-delete directory last.
+// The deepest matching directory decides.
+func (k *keeper) rank(file DuplicateFile) int {
+	path, err := filepath.Abs(file.Path)
+	if err != nil {
+		return len(k.keepIn)
+	}
+	keep, keepDepth := matchDir(path, k.keepIn)
+	_, deleteDepth := matchDir(path, k.preferDelete)
+	switch {
+	case deleteDepth > keepDepth:
+		return len(k.keepIn) + 1
+	case keep >= 0:
+		return keep
+	default:
+		return len(k.keepIn)
+	}
+}
+
+// before reports whether a is rather kept than b by the policy alone
+func (k *keeper) before(a, b DuplicateFile) bool {
+	switch k.policy {
+	case KeepOldest:
+		return a.mtime < b.mtime
+	case KeepNewest:
+		return a.mtime > b.mtime
+	case KeepShortestPath:
+		return len(a.Path) < len(b.Path)
+	case KeepLongestPath:
+		return len(a.Path) > len(b.Path)
+	case KeepFirstAlpha:
+		return a.Path < b.Path
+	}
+	return false
+}
+
+// order sorts the files of each group from the one to keep to the first
+// to delete. Ties keep the order the files were found in.
+func (k *keeper) order(groups [][]DuplicateFile) {
+	for _, group := range groups {
+		ranks := make(map[string]int, len(group))
+		for _, file := range group {
+			ranks[file.Path] = k.rank(file)
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			if ranks[a.Path] != ranks[b.Path] {
+				return ranks[a.Path] < ranks[b.Path]
+			}
+			return k.before(a, b)
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package organizer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layoutFields lists the placeholders a layout template may use
+var layoutFields = map[string]string{
+	"category": "category of the extension, or the extension itself",
+	"ext":      "extension without the dot",
+	"year":     "year of the file's date (see --date-source)",
+	"month":    "month of the file's date (01-12)",
+	"day":      "day of the file's date (01-31)",
+	"week":     "ISO week of the file's date (01-53)",
+	"weekyear": "ISO year the week belongs to, which may differ from year around New Year",
+	"quarter":  "quarter of the file's date (1-4)",
+	"size":     "size bucket: tiny, small, medium, large or huge",
+	"parent":   "name of the directory the file is in",
+	"name":     "original file name",
+	"stem":     "file name without its extension",
+	"counter":  "running number per destination folder, {counter:3} pads to 3 digits",
+}
+
+// LayoutFields returns the placeholders of the layout language with a
+// short description of each
+func LayoutFields() map[string]string {
+	fields := make(map[string]string, len(layoutFields))
+	for k, v := range layoutFields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// layoutToken is a literal or a placeholder of a layout segment
+type layoutToken struct {
+	literal string
+	field   string
+	width   int
+	// source overrides the layout's date source for date fields
+	source string
+}
+
+// Layout is a parsed destination template such as
+// "{category}/{year}/{month}/{name}". The template is relative to the
+// target directory; when it references none of {name}, {stem} or
+// {counter} the original file name is appended.
+type Layout struct {
+	raw  string
+	dirs [][]layoutToken
+	// file is nil when files keep their original name
+	file       []layoutToken
+	ext        ExtensionStrategy
+	categories map[string]string
+	dateSource string
+}
+
+// ParseLayout parses a layout template. Unknown placeholders, unbalanced
+// braces and templates that could leave the target directory are
+// rejected.
+func ParseLayout(template string, opts Options) (*Layout, error) {
+	if strings.TrimSpace(template) == "" {
+		return nil, fmt.Errorf("layout is empty")
+	}
+	if filepath.IsAbs(template) || strings.HasPrefix(template, "/") || strings.HasPrefix(template, `\`) {
+		return nil, fmt.Errorf("layout %q must be relative to the target directory", template)
+	}
+
+	template = strings.TrimRight(template, `/\`)
+
+	categories, err := CategoryIndex(categoryMap(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Layout{
+		raw: template,
+		ext: ExtensionStrategy{
+			FullExt:       opts.FullExt,
+			CaseSensitive: opts.CaseSensitive,
+			NoExtFolder:   opts.NoExtFolder,
+			Quiet:         true,
+		},
+		categories: categories,
+		dateSource: opts.DateSource,
+	}
+
+	segments := strings.FieldsFunc(template, func(r rune) bool { return r == '/' || r == '\\' })
+	if len(segments) != strings.Count(template, "/")+strings.Count(template, `\`)+1 {
+		return nil, fmt.Errorf("layout %q has an empty path segment", template)
+	}
+
+	named := false
+	for i, segment := range segments {
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("layout %q must not contain %q segments", template, segment)
+		}
+		tokens, err := parseLayoutSegment(segment)
+		if err != nil {
+			return nil, fmt.Errorf("layout %q: %w", template, err)
+		}
+		last := i == len(segments)-1
+		for _, t := range tokens {
+			switch t.field {
+			case "name", "stem":
+				named = true
+			case "counter":
+				if !last {
+					return nil, fmt.Errorf("layout %q: {counter} may only be used in the file name", template)
+				}
+				named = true
+			}
+		}
+		if last && named {
+			l.file = tokens
+		} else {
+			l.dirs = append(l.dirs, tokens)
+		}
+	}
+	if named {
+		for _, segment := range l.dirs {
+			for _, t := range segment {
+				if t.field == "name" || t.field == "stem" {
+					return nil, fmt.Errorf("layout %q: {%s} may only be used in the file name", template, t.field)
+				}
+			}
+		}
+	}
+	return l, nil
+}
+
+// parseLayoutSegment splits one path segment into literals and
+// placeholders. "{{" and "}}" stand for literal braces.
+func parseLayoutSegment(segment string) ([]layoutToken, error) {
+	var tokens []layoutToken
+	var lit strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch {
+		case c == '{' && i+1 < len(segment) && segment[i+1] == '{':
+			lit.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(segment) && segment[i+1] == '}':
+			lit.WriteByte('}')
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("unmatched '}' in %q", segment)
+		case c == '{':
+			end := strings.IndexByte(segment[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '{' in %q", segment)
+			}
+			if lit.Len() > 0 {
+				tokens = append(tokens, layoutToken{literal: lit.String()})
+				lit.Reset()
+			}
+			t, err := parseLayoutField(segment[i+1 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i += end
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	if lit.Len() > 0 {
+		tokens = append(tokens, layoutToken{literal: lit.String()})
+	}
+	return tokens, nil
+}
+
+func parseLayoutField(spec string) (layoutToken, error) {
+	field, arg, hasArg := strings.Cut(spec, ":")
+	if _, ok := layoutFields[field]; !ok {
+		return layoutToken{}, fmt.Errorf("unknown placeholder {%s}", spec)
+	}
+	t := layoutToken{field: field}
+	if !hasArg {
+		return t, nil
+	}
+	switch field {
+	case "counter":
+		width, err := strconv.Atoi(arg)
+		if err != nil || width < 1 || width > 12 {
+			return layoutToken{}, fmt.Errorf("invalid counter width in {%s}", spec)
+		}
+		t.width = width
+	case "year", "month", "day", "week", "weekyear", "quarter":
+		if _, ok := dateChains[arg]; !ok {
+			return layoutToken{}, fmt.Errorf("unknown date source in {%s}", spec)
+		}
+		t.source = arg
+	default:
+		return layoutToken{}, fmt.Errorf("placeholder {%s} takes no argument", field)
+	}
+	return t, nil
+}
+
+// String returns the template the layout was parsed from
+func (l *Layout) String() string {
+	return l.raw
+}
+
+// Name returns "layout"
+func (l *Layout) Name() string {
+	return "layout"
+}
+
+// Destination renders the folder part of the layout for entry. Files
+// whose placeholders cannot be filled, such as {ext} of a file without an
+// extension when no NoExtFolder is set, are left alone.
+func (l *Layout) Destination(entry Entry) (string, bool) {
+	parts := make([]string, 0, len(l.dirs))
+	for _, segment := range l.dirs {
+		part, ok := l.render(segment, entry, 0, false)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, part)
+	}
+	return filepath.Join(parts...), true
+}
+
+// FileName renders the file name part of the layout for entry. counter
+// is the running number of the file within its destination folder. The
+// name of a file without an extension gets none: {ext} and {category}
+// are empty and the dot next to them is dropped, so "{stem}.{ext}" keeps
+// "Makefile" as it is.
+func (l *Layout) FileName(entry Entry, counter int) (string, bool) {
+	if l.file == nil {
+		return entry.Name(), true
+	}
+	return l.render(l.file, entry, counter, true)
+}
+
+// counted reports whether the file name uses {counter}
+func (l *Layout) counted() bool {
+	for _, t := range l.file {
+		if t.field == "counter" {
+			return true
+		}
+	}
+	return false
+}
+
+// render fills in the placeholders of one segment, the file name when
+// file is set
+func (l *Layout) render(tokens []layoutToken, entry Entry, counter int, file bool) (string, bool) {
+	parts := make([]string, len(tokens))
+	for i, t := range tokens {
+		if t.field == "" {
+			parts[i] = t.literal
+			continue
+		}
+		value, ok := l.value(t, entry, counter, file)
+		if !ok {
+			return "", false
+		}
+		parts[i] = value
+	}
+	if file {
+		// Drop the dot that would lead to a missing extension
+		for i, t := range tokens {
+			if parts[i] != "" || (t.field != "ext" && t.field != "category") {
+				continue
+			}
+			switch {
+			case i > 0 && tokens[i-1].field == "" && strings.HasSuffix(parts[i-1], "."):
+				parts[i-1] = strings.TrimSuffix(parts[i-1], ".")
+			case i+1 < len(tokens) && tokens[i+1].field == "" && strings.HasPrefix(parts[i+1], "."):
+				parts[i+1] = strings.TrimPrefix(parts[i+1], ".")
+			}
+		}
+	}
+	return sanitizeSegment(strings.Join(parts, "")), true
+}
+
+func (l *Layout) value(t layoutToken, entry Entry, counter int, file bool) (string, bool) {
+	var mod time.Time
+	switch t.field {
+	case "year", "month", "day", "week", "weekyear", "quarter":
+		source := l.dateSource
+		if t.source != "" {
+			source = t.source
+		}
+		mod, _ = entry.Date(source)
+	}
+	switch t.field {
+	case "category", "ext":
+		// NoExtFolder names folders, not files
+		if file && getExtension(entry.Name(), l.ext.FullExt) == "" {
+			return "", true
+		}
+		ext, ok := l.ext.extension(entry)
+		if !ok {
+			return "", false
+		}
+		if ext == "" {
+			return l.ext.NoExtFolder, true
+		}
+		if t.field == "category" {
+			if cat, ok := l.categories[strings.ToLower(ext)]; ok {
+				return cat, true
+			}
+		}
+		return ext, true
+	case "year":
+		return fmt.Sprintf("%04d", mod.Year()), true
+	case "month":
+		return fmt.Sprintf("%02d", mod.Month()), true
+	case "day":
+		return fmt.Sprintf("%02d", mod.Day()), true
+	case "week":
+		_, week := mod.ISOWeek()
+		return fmt.Sprintf("%02d", week), true
+	case "weekyear":
+		year, _ := mod.ISOWeek()
+		return fmt.Sprintf("%04d", year), true
+	case "quarter":
+		return strconv.Itoa(quarter(mod)), true
+	case "size":
+		return sizeBucket(entry.Info.Size()), true
+	case "parent":
+		dir, err := filepath.Abs(filepath.Dir(entry.Path))
+		if err != nil {
+			return "", false
+		}
+		return filepath.Base(dir), true
+	case "name":
+		return entry.Name(), true
+	case "stem":
+		name := entry.Name()
+		ext := getExtension(name, l.ext.FullExt)
+		if ext == "" {
+			return name, true
+		}
+		return strings.TrimSuffix(name, "."+ext), true
+	case "counter":
+		return fmt.Sprintf("%0*d", t.width, counter), true
+	}
+	return "", false
+}
+
+// sizeBucket names the size class of a file
+func sizeBucket(size int64) string {
+	switch {
+	case size < 10<<10:
+		return "tiny"
+	case size < 1<<20:
+		return "small"
+	case size < 100<<20:
+		return "medium"
+	case size < 1<<30:
+		return "large"
+	default:
+		return "huge"
+	}
+}
+
+// sanitizeSegment keeps a rendered value from introducing new path
+// segments or escaping the target directory
+func sanitizeSegment(s string) string {
+	s = strings.NewReplacer("/", "_", `\`, "_", "\x00", "_").Replace(s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
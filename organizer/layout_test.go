@@ -0,0 +1,91 @@
+package organizer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLayoutFileNameWithoutExtension(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		layout, file, folder, name string
+	}{
+		{"{category}/{stem}.{ext}", "Makefile", "Misc", "Makefile"},
+		{"{category}/{stem}.{ext}", "a.jpg", "Images", "a.jpg"},
+		{"{ext}/{ext}.{stem}", "Makefile", "Misc", "Makefile"},
+		{"{ext}/{stem}.{ext}.bak", "Makefile", "Misc", "Makefile.bak"},
+		{"{ext}/{stem}-{category}", "Makefile", "Misc", "Makefile-"},
+		{"{ext}/{stem}.{category}", "notes.txt", "txt", "notes.Documents"},
+	}
+	for _, tt := range tests {
+		l, err := ParseLayout(tt.layout, Options{NoExtFolder: "Misc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, tt.file)
+		entry := newEntry(path, writeFile(t, path, ""), nil)
+		folder, ok := l.Destination(entry)
+		if !ok || folder != tt.folder {
+			t.Errorf("%s with %s: folder = %q, %v, want %q", tt.layout, tt.file, folder, ok, tt.folder)
+		}
+		name, ok := l.FileName(entry, 1)
+		if !ok || name != tt.name {
+			t.Errorf("%s with %s: name = %q, %v, want %q", tt.layout, tt.file, name, ok, tt.name)
+		}
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		template string
+		// dirs and file count the folder segments and whether the last
+		// segment names the file
+		dirs int
+		file bool
+		err  bool
+	}{
+		{template: "{category}", dirs: 1},
+		{template: "{category}/{year}/{month}/", dirs: 3},
+		{template: `{year}\{month}`, dirs: 2},
+		{template: "{year:filename}/{month:exif}", dirs: 2},
+		{template: "{year}/{parent}-{stem}_{counter:3}.{ext}", dirs: 1, file: true},
+		{template: "{name}", file: true},
+		{template: "{{literal}}/{ext}", dirs: 2},
+		{template: "Photos/{{{year}}}", dirs: 2},
+
+		{template: "", err: true},
+		{template: "  ", err: true},
+		{template: "/abs/{year}", err: true},
+		{template: `\abs`, err: true},
+		{template: "a//b", err: true},
+		{template: "../{year}", err: true},
+		{template: "{year}/./{month}", err: true},
+		{template: "{bogus}", err: true},
+		{template: "{year", err: true},
+		{template: "year}", err: true},
+		{template: "{counter}/{name}", err: true},
+		{template: "{stem}/{counter}", err: true},
+		{template: "{name}/{year}", err: true},
+		{template: "{counter:0}", err: true},
+		{template: "{counter:x}", err: true},
+		{template: "{counter:13}", err: true},
+		{template: "{year:bogus}", err: true},
+		{template: "{ext:1}", err: true},
+	}
+	for _, tt := range tests {
+		l, err := ParseLayout(tt.template, Options{})
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseLayout(%q) succeeded, want an error", tt.template)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLayout(%q): %v", tt.template, err)
+			continue
+		}
+		if len(l.dirs) != tt.dirs || (l.file != nil) != tt.file {
+			t.Errorf("ParseLayout(%q) has %d folders and file name %v, want %d and %v", tt.template, len(l.dirs), l.file != nil, tt.dirs, tt.file)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package organizer
+
+import "os"
+
+// lockFile does nothing where gorder cannot lock files; concurrent runs
+// may then lose each other's cache entries
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,209 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// mediaExts lists the extensions of the containers mediaDate understands
+var mediaExts = map[string]bool{
+	"mp4": true, "m4v": true, "mov": true, "qt": true, "3gp": true, "3g2": true,
+	"m4a": true, "m4b": true, "mkv": true, "mka": true, "webm": true,
+}
+
+// Epochs of the creation times stored by ISO base media and Matroska files
+var (
+	mp4Epoch      = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	matroskaEpoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Matroska element IDs read by matroskaDate
+const (
+	ebmlHeader      = 0x1A45DFA3
+	ebmlSegment     = 0x18538067
+	ebmlInfo        = 0x1549A966
+	ebmlDateUTC     = 0x4461
+	ebmlCluster     = 0x1F43B675
+	ebmlUnknownSize = -1
+)
+
+var errNoMediaDate = errors.New("no creation date")
+
+// mediaDate returns the time a video or audio recording was made, from
+// the mvhd box of MP4, QuickTime and 3GP files or the DateUTC element of
+// Matroska and WebM files. Both store UTC, so the time is converted to
+// local time for grouping like the other sources.
+func mediaDate(path string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	var head [8]byte
+	n, _ := io.ReadFull(f, head[:])
+	h := head[:n]
+
+	var t time.Time
+	switch {
+	case bytes.HasPrefix(h, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		t, err = matroskaDate(f)
+	case n == 8:
+		// ISO base media files start with an ftyp box, but older
+		// QuickTime files may go straight to moov, mdat or wide
+		t, err = mvhdDate(f)
+	default:
+		return time.Time{}, false
+	}
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.In(time.Local), true
+}
+
+// mvhdDate returns the creation time of the movie header box of an ISO
+// base media file, which is in the moov box at the start or the end of
+// the file
+func mvhdDate(r io.ReaderAt) (time.Time, error) {
+	off, size, err := locateBox(r, 0, -1, "moov")
+	if err != nil {
+		return time.Time{}, err
+	}
+	mvhd, err := findBox(r, off, off+size, "mvhd")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// mvhd is a full box: its version decides the size of the times
+	var secs uint64
+	switch {
+	case len(mvhd) >= 8 && mvhd[0] == 0:
+		secs = uint64(binary.BigEndian.Uint32(mvhd[4:]))
+	case len(mvhd) >= 12 && mvhd[0] == 1:
+		secs = binary.BigEndian.Uint64(mvhd[4:])
+	default:
+		return time.Time{}, errNoMediaDate
+	}
+	// Cameras that do not know the time leave it at zero
+	if secs == 0 || secs > 1<<62 {
+		return time.Time{}, errNoMediaDate
+	}
+	return time.Unix(mp4Epoch.Unix()+int64(secs), 0), nil
+}
+
+// matroskaDate returns the DateUTC element of the segment information of
+// a Matroska or WebM file
+func matroskaDate(r io.ReaderAt) (time.Time, error) {
+	e := ebmlReader{r: r}
+	id, size, pos, err := e.element(0)
+	if err != nil || id != ebmlHeader || size == ebmlUnknownSize {
+		return time.Time{}, errNoMediaDate
+	}
+
+	id, size, pos, err = e.element(pos + size)
+	if err != nil || id != ebmlSegment {
+		return time.Time{}, errNoMediaDate
+	}
+	// The segment information comes before the first cluster, so a
+	// segment of unknown size, as written by live recorders, is fine
+	end := int64(-1)
+	if size != ebmlUnknownSize {
+		end = pos + size
+	}
+	for end < 0 || pos < end {
+		id, size, pos, err = e.element(pos)
+		if err != nil || id == ebmlCluster || size == ebmlUnknownSize {
+			return time.Time{}, errNoMediaDate
+		}
+		if id == ebmlInfo {
+			return e.dateUTC(pos, pos+size)
+		}
+		pos += size
+	}
+	return time.Time{}, errNoMediaDate
+}
+
+// ebmlReader reads the elements of an EBML document such as a Matroska
+// file
+type ebmlReader struct {
+	r io.ReaderAt
+}
+
+// element reads the header of the element at off, returning its ID, the
+// size of its data, or ebmlUnknownSize, and the offset of its data
+func (e ebmlReader) element(off int64) (uint32, int64, int64, error) {
+	id, n, err := e.vint(off, 4, true)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	size, m, err := e.vint(off+int64(n), 8, false)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	// A size with all its value bits set is unknown
+	if size == 1<<(7*m)-1 {
+		return uint32(id), ebmlUnknownSize, off + int64(n+m), nil
+	}
+	if size > 1<<62 {
+		return 0, 0, 0, errNoMediaDate
+	}
+	return uint32(id), int64(size), off + int64(n+m), nil
+}
+
+// vint reads the variable size integer at off, of at most maxLen bytes,
+// returning its value and length. IDs keep their length marker, while
+// sizes do not.
+func (e ebmlReader) vint(off int64, maxLen int, keepMarker bool) (uint64, int, error) {
+	var buf [8]byte
+	if _, err := e.r.ReadAt(buf[:1], off); err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for length <= 8 && buf[0]&(0x80>>(length-1)) == 0 {
+		length++
+	}
+	if length > maxLen {
+		return 0, 0, errNoMediaDate
+	}
+	if length > 1 {
+		if _, err := e.r.ReadAt(buf[1:length], off+1); err != nil {
+			return 0, 0, err
+		}
+	}
+	v := uint64(buf[0])
+	if !keepMarker {
+		v &^= 0x80 >> (length - 1)
+	}
+	for _, c := range buf[1:length] {
+		v = v<<8 | uint64(c)
+	}
+	return v, length, nil
+}
+
+// dateUTC returns the DateUTC element between start and end, the
+// nanoseconds since the start of 2001 in UTC
+func (e ebmlReader) dateUTC(start, end int64) (time.Time, error) {
+	for pos := start; pos < end; {
+		id, size, data, err := e.element(pos)
+		if err != nil || size == ebmlUnknownSize {
+			return time.Time{}, errNoMediaDate
+		}
+		if id == ebmlDateUTC && size == 8 {
+			var buf [8]byte
+			if _, err := e.r.ReadAt(buf[:], data); err != nil {
+				return time.Time{}, err
+			}
+			ns := int64(binary.BigEndian.Uint64(buf[:]))
+			if ns == 0 {
+				return time.Time{}, errNoMediaDate
+			}
+			return matroskaEpoch.Add(time.Duration(ns)), nil
+		}
+		pos = data + size
+	}
+	return time.Time{}, errNoMediaDate
+}
@@ -0,0 +1,176 @@
+package organizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMediaDate = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func be64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+// mvhdBox returns a movie header box of version 0 or 1 created at secs
+// since 1904
+func mvhdBox(version byte, secs uint64) []byte {
+	if version == 1 {
+		return isoBox("mvhd", []byte{1, 0, 0, 0}, be64(secs), be64(secs), make([]byte, 88))
+	}
+	return isoBox("mvhd", be32(0), be32(int(secs)), be32(int(secs)), make([]byte, 88))
+}
+
+// testMP4 returns an MP4 file with moov holding an mvhd box, before or
+// after the media data
+func testMP4(mvhd []byte, moovLast bool) []byte {
+	ftyp := isoBox("ftyp", []byte("isom"), be32(512), []byte("isomiso2mp41"))
+	moov := isoBox("moov", mvhd, isoBox("trak", make([]byte, 16)))
+	mdat := isoBox("mdat", make([]byte, 64))
+	if moovLast {
+		return join(ftyp, mdat, moov)
+	}
+	return join(ftyp, moov, mdat)
+}
+
+var mp4Secs = uint64(testMediaDate.Unix() - mp4Epoch.Unix())
+
+// ebmlElement returns an EBML element with a size of one or two bytes
+func ebmlElement(id []byte, data ...[]byte) []byte {
+	d := join(data...)
+	if len(d) < 127 {
+		return join(id, []byte{0x80 | byte(len(d))}, d)
+	}
+	return join(id, []byte{0x40 | byte(len(d)>>8), byte(len(d))}, d)
+}
+
+var (
+	ebmlHeaderID  = []byte{0x1A, 0x45, 0xDF, 0xA3}
+	ebmlSegmentID = []byte{0x18, 0x53, 0x80, 0x67}
+	ebmlInfoID    = []byte{0x15, 0x49, 0xA9, 0x66}
+	ebmlSeekID    = []byte{0x11, 0x4D, 0x9B, 0x74}
+	ebmlClusterID = []byte{0x1F, 0x43, 0xB6, 0x75}
+	ebmlDateID    = []byte{0x44, 0x61}
+	// ebmlTimecodeScaleID is an Info element before DateUTC
+	ebmlTimecodeScaleID = []byte{0x2A, 0xD7, 0xB1}
+)
+
+var matroskaNs = uint64(testMediaDate.Sub(matroskaEpoch))
+
+func ebmlHead() []byte {
+	return ebmlElement(ebmlHeaderID, ebmlElement([]byte{0x42, 0x82}, []byte("matroska")))
+}
+
+// testMatroska returns a Matroska file whose segment holds elements
+func testMatroska(elements ...[]byte) []byte {
+	return join(ebmlHead(), ebmlElement(ebmlSegmentID, elements...))
+}
+
+func matroskaInfo(ns uint64) []byte {
+	return ebmlElement(ebmlInfoID,
+		ebmlElement(ebmlTimecodeScaleID, be32(1000000)),
+		ebmlElement(ebmlDateID, be64(ns)))
+}
+
+func TestMvhdDate(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"version 0", testMP4(mvhdBox(0, mp4Secs), false), true},
+		{"version 1", testMP4(mvhdBox(1, mp4Secs), false), true},
+		{"moov at the end", testMP4(mvhdBox(0, mp4Secs), true), true},
+		{"no ftyp", isoBox("moov", mvhdBox(0, mp4Secs)), true},
+
+		{"zero time", testMP4(mvhdBox(0, 0), false), false},
+		{"huge time", testMP4(mvhdBox(1, 1<<63), false), false},
+		{"unknown version", testMP4(isoBox("mvhd", []byte{2, 0, 0, 0}, be64(mp4Secs)), false), false},
+		{"short mvhd", testMP4(isoBox("mvhd", be32(0), be16(1)), false), false},
+		{"no mvhd", testMP4(isoBox("trak", make([]byte, 16)), false), false},
+		{"mvhd outside moov", join(isoBox("moov", isoBox("trak")), mvhdBox(0, mp4Secs)), false},
+		{"no moov", isoBox("ftyp", []byte("isom")), false},
+		{"bad box size", join(be32(3), []byte("ftyp"), mvhdBox(0, mp4Secs)), false},
+		{"moov past the end", join(be32(1000), []byte("moov"), mvhdBox(0, mp4Secs)), true},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := mvhdDate(bytes.NewReader(tt.data))
+		if (err == nil) != tt.ok || (tt.ok && !got.Equal(testMediaDate)) {
+			t.Errorf("%s: mvhdDate = %v, %v, want ok %v", tt.name, got, err, tt.ok)
+		}
+	}
+}
+
+func TestMatroskaDate(t *testing.T) {
+	seek := ebmlElement(ebmlSeekID, make([]byte, 10))
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"info first", testMatroska(matroskaInfo(matroskaNs)), true},
+		{"after seek head", testMatroska(seek, matroskaInfo(matroskaNs)), true},
+		{"unknown segment size", join(ebmlHead(), ebmlSegmentID, []byte{0xFF}, seek, matroskaInfo(matroskaNs)), true},
+		{"eight byte segment size", join(ebmlHead(), ebmlSegmentID, []byte{0x01, 0, 0, 0, 0, 0, 0, byte(len(matroskaInfo(matroskaNs)))}, matroskaInfo(matroskaNs)), true},
+
+		{"zero date", testMatroska(matroskaInfo(0)), false},
+		{"no date", testMatroska(ebmlElement(ebmlInfoID, ebmlElement(ebmlTimecodeScaleID, be32(1000000)))), false},
+		{"short date", testMatroska(ebmlElement(ebmlInfoID, ebmlElement(ebmlDateID, be32(1)))), false},
+		{"cluster first", testMatroska(ebmlElement(ebmlClusterID, make([]byte, 4)), matroskaInfo(matroskaNs)), false},
+		{"no info", testMatroska(seek), false},
+		{"unknown info size", testMatroska(ebmlInfoID, []byte{0xFF}, ebmlElement(ebmlDateID, be64(matroskaNs))), false},
+		{"not a segment", join(ebmlHead(), ebmlElement(ebmlInfoID, matroskaInfo(matroskaNs))), false},
+		{"not EBML", join(ebmlSegmentID, []byte{0x80}), false},
+		{"unknown header size", join(ebmlHeaderID, []byte{0xFF}), false},
+		{"id too long", join(ebmlHead(), []byte{0x08, 0, 0, 0, 0, 0x80}), false},
+		{"zero length marker", join(ebmlHead(), []byte{0x00}), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := matroskaDate(bytes.NewReader(tt.data))
+		if (err == nil) != tt.ok || (tt.ok && !got.Equal(testMediaDate)) {
+			t.Errorf("%s: matroskaDate = %v, %v, want ok %v", tt.name, got, err, tt.ok)
+		}
+	}
+}
+
+func TestMediaDateTruncated(t *testing.T) {
+	for name, tt := range map[string]struct {
+		data []byte
+		read func([]byte) (time.Time, error)
+	}{
+		"mp4": {testMP4(mvhdBox(1, mp4Secs), true), func(b []byte) (time.Time, error) { return mvhdDate(bytes.NewReader(b)) }},
+		"mkv": {testMatroska(matroskaInfo(matroskaNs)), func(b []byte) (time.Time, error) { return matroskaDate(bytes.NewReader(b)) }},
+	} {
+		// Every prefix must be parsed without panicking, and any date
+		// found must be the right one
+		for n := range tt.data {
+			if got, err := tt.read(tt.data[:n]); err == nil && !got.Equal(testMediaDate) {
+				t.Errorf("%s cut at %d bytes: date = %v", name, n, got)
+			}
+		}
+	}
+}
+
+func TestMediaDate(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"clip.mp4":  testMP4(mvhdBox(0, mp4Secs), false),
+		"clip.mkv":  testMatroska(matroskaInfo(matroskaNs)),
+		"short.mp4": []byte("ftyp"),
+		"text.mkv":  []byte("not a video file"),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := mediaDate(path)
+		want := strings.HasPrefix(name, "clip")
+		if ok != want || (ok && (!got.Equal(testMediaDate) || got.Location() != time.Local)) {
+			t.Errorf("%s: mediaDate = %v, %v, want ok %v", name, got, ok, want)
+		}
+	}
+}
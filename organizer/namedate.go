@@ -0,0 +1,110 @@
+package organizer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// datePatternGroups lists the named groups a date pattern may use. year
+// is required; a quarter stands for the first day of the quarter.
+var datePatternGroups = map[string]bool{
+	"year": true, "quarter": true, "month": true, "day": true,
+	"hour": true, "minute": true, "second": true,
+}
+
+// builtinDatePatterns match the dates cameras, phones, messengers and
+// screenshot tools put in file names, such as IMG_20230514_101500.jpg,
+// PXL_20230514_101500123.jpg, WhatsApp Image 2022-11-30 at 10.11.12.jpeg,
+// Screenshot 2024-01-03 at 09.12.png, invoice_2023-Q2.pdf or
+// report_2023-05.pdf. They are tried in order, after the configured ones.
+var builtinDatePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?:^|\D)(?P<year>(?:19|20)\d\d)[-_.]?(?P<month>0[1-9]|1[0-2])[-_.]?(?P<day>0[1-9]|[12]\d|3[01])(?:[ _T.-]?(?:at )?(?P<hour>[01]\d|2[0-3])[-_.:h]?(?P<minute>[0-5]\d)(?:[-_.:m]?(?P<second>[0-5]\d))?\d{0,3})?(?:\D|$)`),
+	regexp.MustCompile(`(?:^|\D)(?P<year>(?:19|20)\d\d)[-_ ]?[Qq](?P<quarter>[1-4])(?:\D|$)`),
+	regexp.MustCompile(`(?:^|[^0-9A-Za-z])[Qq](?P<quarter>[1-4])[-_ ]?(?P<year>(?:19|20)\d\d)(?:\D|$)`),
+	regexp.MustCompile(`(?:^|\D)(?P<year>(?:19|20)\d\d)[-_.](?P<month>0[1-9]|1[0-2])(?:\D|$)`),
+}
+
+// compileDatePatterns compiles date patterns given as regular expressions
+// with named groups, such as `scan-(?P<day>\d\d)(?P<month>\d\d)(?P<year>\d{4})`
+func compileDatePatterns(exprs []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date pattern %q: %w", expr, err)
+		}
+		hasYear := false
+		for _, name := range re.SubexpNames()[1:] {
+			if name == "" {
+				continue
+			}
+			if !datePatternGroups[name] {
+				return nil, fmt.Errorf("date pattern %q: unknown group %q (want year, quarter, month, day, hour, minute or second)", expr, name)
+			}
+			hasYear = hasYear || name == "year"
+		}
+		if !hasYear {
+			return nil, fmt.Errorf("date pattern %q has no (?P<year>...) group", expr)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// filenameDate parses a date from a file name, as a wall clock time in
+// loc, using the given patterns and then the built-in ones. Matches that
+// do not make a valid date, such as February 30, are ignored.
+func filenameDate(name string, patterns []*regexp.Regexp, loc *time.Location) (time.Time, bool) {
+	for _, list := range [][]*regexp.Regexp{patterns, builtinDatePatterns} {
+		for _, re := range list {
+			if t, ok := matchDate(re, name, loc); ok {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// matchDate builds the date in loc matched by the named groups of re in
+// name. Missing parts default to the start of the period matched.
+func matchDate(re *regexp.Regexp, name string, loc *time.Location) (time.Time, bool) {
+	m := re.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	parts := map[string]int{"month": 1, "day": 1}
+	for i, group := range re.SubexpNames() {
+		if group == "" || m[i] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i])
+		if err != nil {
+			return time.Time{}, false
+		}
+		parts[group] = n
+	}
+	if q, ok := parts["quarter"]; ok {
+		if q < 1 || q > 4 {
+			return time.Time{}, false
+		}
+		parts["month"] = 3*(q-1) + 1
+	}
+	year := parts["year"]
+	// Two-digit years are taken to be in this century
+	if year < 100 {
+		year += 2000
+	}
+
+	if parts["hour"] > 23 || parts["minute"] > 59 || parts["second"] > 59 {
+		return time.Time{}, false
+	}
+	t := time.Date(year, time.Month(parts["month"]), parts["day"],
+		parts["hour"], parts["minute"], parts["second"], 0, loc)
+	// Reject dates that time.Date normalizes, such as February 30
+	if t.Month() != time.Month(parts["month"]) || t.Day() != parts["day"] {
+		return time.Time{}, false
+	}
+	return t, true
+}
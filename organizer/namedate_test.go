@@ -0,0 +1,85 @@
+package organizer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilenameDateBuiltin(t *testing.T) {
+	date := func(y int, m time.Month, d, h, min, s int) time.Time {
+		return time.Date(y, m, d, h, min, s, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		want time.Time
+		ok   bool
+	}{
+		{"IMG_20230514_101500.jpg", date(2023, 5, 14, 10, 15, 0), true},
+		{"PXL_20230514_101500123.jpg", date(2023, 5, 14, 10, 15, 0), true},
+		{"VID-20210102-WA0001.mp4", date(2021, 1, 2, 0, 0, 0), true},
+		{"WhatsApp Image 2022-11-30 at 10.11.12.jpeg", date(2022, 11, 30, 10, 11, 12), true},
+		{"Screenshot 2024-01-03 at 09.12.png", date(2024, 1, 3, 9, 12, 0), true},
+		{"Screenshot_2024-01-03-09-12-45.png", date(2024, 1, 3, 9, 12, 45), true},
+		{"2019.12.31 party.jpg", date(2019, 12, 31, 0, 0, 0), true},
+		{"scan_1999-07-04T23:59:59.pdf", date(1999, 7, 4, 23, 59, 59), true},
+		{"invoice_2023-Q2.pdf", date(2023, 4, 1, 0, 0, 0), true},
+		{"report 2023q4.pdf", date(2023, 10, 1, 0, 0, 0), true},
+		{"Q3-2022 results.xlsx", date(2022, 7, 1, 0, 0, 0), true},
+		{"report_2023-05.pdf", date(2023, 5, 1, 0, 0, 0), true},
+
+		// Invalid dates and numbers that only look like dates
+		{"IMG_20230230_101500.jpg", time.Time{}, false},
+		{"IMG_20231301.jpg", time.Time{}, false},
+		{"order_120230514.txt", time.Time{}, false},
+		{"id-202305141.txt", time.Time{}, false},
+		{"2023-Q5.pdf", time.Time{}, false},
+		{"FAQ12023.pdf", time.Time{}, false},
+		{"v2.13.0.tar.gz", time.Time{}, false},
+		{"notes.txt", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := filenameDate(tt.name, nil, time.UTC)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("filenameDate(%q) = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFilenameDateConfigured(t *testing.T) {
+	patterns, err := compileDatePatterns([]string{`scan-(?P<day>\d\d)(?P<month>\d\d)(?P<year>\d\d)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone := time.FixedZone("CET", 3600)
+	tests := []struct {
+		name string
+		want time.Time
+	}{
+		// Configured patterns come first; two-digit years are in this century
+		{"scan-140523.pdf", time.Date(2023, 5, 14, 0, 0, 0, 0, zone)},
+		// The built-in ones still apply to other names
+		{"IMG_20230514_101500.jpg", time.Date(2023, 5, 14, 10, 15, 0, 0, zone)},
+	}
+	for _, tt := range tests {
+		got, ok := filenameDate(tt.name, patterns, zone)
+		if !ok || !got.Equal(tt.want) || got.Location() != zone {
+			t.Errorf("filenameDate(%q) = %v, %v, want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCompileDatePatterns(t *testing.T) {
+	for expr, valid := range map[string]bool{
+		`(?P<year>\d{4})-(?P<month>\d\d)`: true,
+		`(?P<year>\d{4})(\d\d)`:           true,
+		`(?P<year>\d{4})Q(?P<quarter>\d)`: true,
+		`(?P<month>\d\d)`:                 false,
+		`(?P<year>\d{4})-(?P<mon>\d\d)`:   false,
+		`(?P<year>\d{4}`:                  false,
+	} {
+		if _, err := compileDatePatterns([]string{expr}); (err == nil) != valid {
+			t.Errorf("compileDatePatterns(%q) = %v, want valid %v", expr, err, valid)
+		}
+	}
+}
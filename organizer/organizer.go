@@ -0,0 +1,339 @@
+// Package organizer sorts the files of a directory into folders by
+// extension, category or modification date. It is the library behind the
+// gorder command and can be embedded in other programs.
+package organizer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogName is the pipe-separated undo log written by older versions to
+// the organized directory. Undo still reads it; JournalName replaces it.
+const LogName = ".gorder_log.txt"
+
+// Options configures an Organizer. The zero value organizes the current
+// directory by extension into gorder_<ext> folders.
+type Options struct {
+	// Dir is the directory whose files are organized (default ".")
+	Dir string
+	// Target is the directory the grouping folders are created in (default ".")
+	Target string
+	// Recursive also organizes files found in subdirectories of Dir
+	Recursive bool
+	// Mode is how files are put in their folders: OpMove (the default),
+	// OpCopy, OpHardlink or OpSymlink. Rules that copy or trash files
+	// keep doing so.
+	Mode string
+	// OnConflict decides what happens when a destination is taken:
+	// ConflictRename (the default), ConflictSkip, ConflictOverwrite,
+	// ConflictKeepNewer, ConflictKeepLarger or ConflictDedupe. Replaced
+	// and deduplicated files are moved to the trash.
+	OnConflict string
+	// FullExt uses the last two extensions (e.g. tar.gz) instead of one
+	FullExt bool
+	// Categories groups extensions into categories such as Images
+	Categories bool
+	// CaseSensitive keeps the case of extensions (JPG vs jpg)
+	CaseSensitive bool
+	// DateMode groups by date: year, quarter, month, week, day,
+	// fiscal-year or decade, or several nested with slashes such as
+	// "year/month"
+	DateMode string
+	// DateFormat names date folders with a Go layout such as "2006/01" or
+	// a strftime format such as "%Y/%m" instead of DateMode, and implies
+	// grouping by date
+	DateFormat string
+	// FiscalYearStart is the first month of the fiscal year used by the
+	// fiscal-year date mode (January when zero)
+	FiscalYearStart time.Month
+	// DateSource is where dates used by DateMode and layouts are read
+	// from: DateSourceAuto (the default), DateSourceExif, DateSourceMedia,
+	// DateSourceMtime, DateSourceCtime, DateSourceBirth or
+	// DateSourceFilename. Files that
+	// lack the date fall back to other sources, see Entry.Date.
+	DateSource string
+	// DatePatterns are regular expressions with named groups (year,
+	// quarter, month, day, hour, minute and second) that find dates in
+	// file names, tried before the built-in patterns
+	DatePatterns []string
+	// TimeZone is the zone dates are grouped in, so that the same files
+	// land in the same folders on every machine. Timestamps are converted
+	// to it, and dates recorded as a wall clock time without a zone, such
+	// as EXIF dates without offset tags and dates in file names, are taken
+	// to be in it. When nil, local time is used and EXIF dates keep the
+	// offset they were recorded with.
+	TimeZone *time.Location
+	// NoExtFolder receives files without an extension; they are skipped if empty
+	NoExtFolder string
+	// Include limits processing to these extensions, names, globs or
+	// "re:" regular expressions
+	Include []string
+	// Exclude skips files matching these entries, see Include
+	Exclude []string
+	// ExcludeDirs prunes the directories whose name, or path relative to
+	// Dir when the glob contains a slash, matches one of these globs.
+	// .gorderignore files are honored as well.
+	ExcludeDirs []string
+	// Quiet drops the gorder_ prefix from extension folders
+	Quiet bool
+	// GroupBy names the registered strategies to group by, separated by
+	// commas or slashes, e.g. "category/date". When empty it is derived
+	// from Categories, DateMode and DateFormat.
+	GroupBy string
+	// Strategy overrides GroupBy with a custom strategy
+	Strategy Strategy
+	// CategoryMap replaces the built-in CategoryMap, e.g. with the
+	// categories of a Config
+	CategoryMap map[string][]string
+	// Layout is a destination template such as "{category}/{year}/{name}"
+	// that overrides GroupBy and may also rename files. See ParseLayout.
+	Layout string
+	// Rules route matching files before the strategy is consulted
+	Rules []Rule
+}
+
+// Organizer plans and applies the organization of a directory
+type Organizer struct {
+	opts     Options
+	filter   *filter
+	strategy Strategy
+	layout   *Layout
+	rules    []*Rule
+	// dateSettings hold the compiled DatePatterns and the TimeZone
+	dateSettings *dateSettings
+}
+
+// New returns an Organizer for opts
+func New(opts Options) (*Organizer, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Target == "" {
+		opts.Target = "."
+	}
+	if opts.Mode == "" {
+		opts.Mode = OpMove
+	}
+	if err := checkMode(opts.Mode); err != nil {
+		return nil, err
+	}
+	if err := checkPlanPolicy(opts.OnConflict); err != nil {
+		return nil, err
+	}
+	if err := checkDateSource(opts.DateSource); err != nil {
+		return nil, err
+	}
+	if opts.DateMode != "" {
+		if err := checkDateMode(opts.DateMode); err != nil {
+			return nil, err
+		}
+	}
+	if opts.FiscalYearStart < 0 || opts.FiscalYearStart > time.December {
+		return nil, fmt.Errorf("invalid fiscal year start month %d", opts.FiscalYearStart)
+	}
+	datePatterns, err := compileDatePatterns(opts.DatePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := newFilter(opts.Include, opts.Exclude, opts.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+	o := &Organizer{
+		opts:     opts,
+		filter:   filter,
+		strategy: opts.Strategy,
+
+		dateSettings: &dateSettings{patterns: datePatterns, loc: opts.TimeZone},
+	}
+
+	if opts.Layout != "" {
+		layout, err := ParseLayout(opts.Layout, opts)
+		if err != nil {
+			return nil, err
+		}
+		o.layout = layout
+		o.strategy = layout
+	}
+	if o.strategy == nil {
+		var err error
+		o.strategy, err = NewStrategy(groupBy(opts), opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, r := range opts.Rules {
+		rule := r
+		if err := rule.compile(opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", rule.label(i), err)
+		}
+		o.rules = append(o.rules, &rule)
+	}
+	return o, nil
+}
+
+// groupBy returns the strategies opts asks for
+func groupBy(opts Options) string {
+	if opts.GroupBy != "" {
+		return opts.GroupBy
+	}
+	var names []string
+	if opts.Categories {
+		names = append(names, "category")
+	}
+	if opts.DateMode != "" || opts.DateFormat != "" {
+		names = append(names, "date")
+	}
+	if len(names) == 0 {
+		names = append(names, "extension")
+	}
+	return strings.Join(names, "/")
+}
+
+// Plan decides where every file should go without touching the disk
+func (o *Organizer) Plan(ctx context.Context) (*Plan, error) {
+	p := newPlanner(filepath.Join(o.opts.Dir, JournalName))
+	p.onConflict = o.opts.OnConflict
+	separateTarget := filepath.Clean(o.opts.Target) != filepath.Clean(o.opts.Dir)
+
+	err := walkTree(ctx, o.opts.Dir, o.opts.ExcludeDirs, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			// Only descend in recursive mode, and never into a separate
+			// target directory holding files already organized
+			if !o.opts.Recursive || separateTarget && within(path, o.opts.Target) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		o.plan(p, path, info)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+	return p.plan, nil
+}
+
+// Apply plans the organization and performs it, recording every move in
+// the undo log
+func (o *Organizer) Apply(ctx context.Context) (*Result, error) {
+	plan, err := o.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(ctx, plan)
+}
+
+// plan adds the move for a single file to p
+func (o *Organizer) plan(p *planner, path string, info os.FileInfo) {
+	name := info.Name()
+
+	// Skip gorder's own files
+	if isGorderFile(name) {
+		return
+	}
+
+	// Check if file should be processed based on include/exclude
+	if !o.filter.allows(name, o.relPath(path)) {
+		return
+	}
+
+	entry := newEntry(path, info, o.dateSettings)
+	move := Move{Source: path, Op: o.opts.Mode}
+
+	// Age conditions are measured from the plan's creation, so that every
+	// file of a plan is judged at the same instant
+	if rule, i := o.matchRule(entry, p.plan.Created); rule != nil {
+		move.Reason = rule.label(i) + ": " + rule.Action
+		var ok bool
+		switch rule.Action {
+		case ActionSkip:
+			return
+		case ActionTrash:
+			dir, err := trashFilesDir()
+			if err != nil {
+				p.skip(path, err.Error())
+				return
+			}
+			move.Op = OpTrash
+			move.Dest, ok = filepath.Join(dir, name), true
+		case ActionRename:
+			move.Dest, ok = o.destination(p, entry, nil, rule.layout, filepath.Dir(path))
+		case ActionCopy, ActionMove:
+			if rule.Action == ActionCopy {
+				move.Op = OpCopy
+			}
+			if rule.layout != nil {
+				move.Dest, ok = o.destination(p, entry, rule.layout, rule.layout, o.opts.Target)
+			} else {
+				move.Dest, ok = o.destination(p, entry, o.strategy, o.layout, o.opts.Target)
+			}
+		}
+		if !ok {
+			return
+		}
+	} else {
+		var ok bool
+		move.Dest, ok = o.destination(p, entry, o.strategy, o.layout, o.opts.Target)
+		if !ok {
+			return
+		}
+		move.Reason = o.strategy.Name() + ": " + filepath.Dir(move.Dest)
+		if o.layout != nil {
+			move.Reason = "layout " + o.layout.String()
+		}
+	}
+
+	// Files already where they belong stay there
+	if filepath.Clean(path) == move.Dest {
+		return
+	}
+	p.add(move, info)
+}
+
+// isGorderFile reports whether name is one of the journal, log or
+// ignore files gorder keeps in a directory
+func isGorderFile(name string) bool {
+	return name == JournalName || name == LogName || name == IgnoreName
+}
+
+// destination returns where entry goes below root: the folder chosen by
+// strategy and, when layout is set, the file name it renders
+func (o *Organizer) destination(p *planner, entry Entry, strategy Strategy, layout *Layout, root string) (string, bool) {
+	folder := root
+	if strategy != nil {
+		folderName, ok := strategy.Destination(entry)
+		if !ok {
+			return "", false
+		}
+		folder = filepath.Join(root, folderName)
+	}
+
+	name := entry.Name()
+	if layout != nil {
+		var ok bool
+		// The counter only advances once a move is placed, see
+		// planner.place. Numbers whose file already exists are passed
+		// over rather than fought over.
+		counter := p.counters[folder] + 1
+		for {
+			name, ok = layout.FileName(entry, counter)
+			if !ok {
+				return "", false
+			}
+			dest := filepath.Join(folder, name)
+			if !layout.counted() || dest == filepath.Clean(entry.Path) || free(dest, p.taken) {
+				break
+			}
+			counter++
+		}
+	}
+	return filepath.Join(folder, name), true
+}
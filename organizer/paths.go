@@ -0,0 +1,73 @@
+package organizer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func getExtension(name string, full bool) string {
+	if full {
+		parts := strings.Split(name, ".")
+		if len(parts) > 2 {
+			return strings.Join(parts[len(parts)-2:], ".")
+		}
+	}
+	return strings.TrimPrefix(filepath.Ext(name), ".")
+}
+
+// avoidCollision returns path, or the first free "name (N).ext" variant
+// of it if path exists on disk or is taken by the plan. taken maps the
+// paths planned moves put files at to true, and those they move files
+// away from to false.
+func avoidCollision(path string, taken map[string]bool) string {
+	if free(path, taken) {
+		return path
+	}
+
+	base, ext := splitExt(filepath.Base(path))
+	dir := filepath.Dir(path)
+
+	// Try sequential numbering
+	for i := 1; ; i++ {
+		newName := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		newPath := filepath.Join(dir, newName)
+		if free(newPath, taken) {
+			return newPath
+		}
+	}
+}
+
+// splitExt splits name into its stem and extension, keeping compressed
+// tarball extensions such as .tar.gz whole. Dot files like .bashrc have
+// no extension.
+func splitExt(name string) (string, string) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" {
+		return name, ""
+	}
+	if inner := filepath.Ext(stem); strings.EqualFold(inner, ".tar") && inner != stem {
+		return strings.TrimSuffix(stem, inner), inner + ext
+	}
+	return stem, ext
+}
+
+func free(path string, taken map[string]bool) bool {
+	if t, ok := taken[path]; ok {
+		return !t
+	}
+	_, err := os.Lstat(path)
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// within reports whether path lies inside dir
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,421 @@
+package organizer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// PlanVersion is the version of the JSON plan format
+const PlanVersion = 1
+
+var (
+	// ErrSourceMissing is reported when a planned source no longer exists
+	ErrSourceMissing = errors.New("source no longer exists")
+	// ErrSourceChanged is reported when a planned source was modified
+	// after the plan was made
+	ErrSourceChanged = errors.New("source changed since the plan was made")
+	// ErrDestExists is reported when something now occupies a planned
+	// destination
+	ErrDestExists = errors.New("destination already exists")
+)
+
+// Operations a Move can perform
+const (
+	// OpMove moves the source to the destination
+	OpMove = "move"
+	// OpCopy copies the source to the destination
+	OpCopy = "copy"
+	// OpTrash moves the source into the user's trash
+	OpTrash = "trash"
+	// OpHardlink creates a hard link to the source at the destination
+	OpHardlink = "hardlink"
+	// OpSymlink creates a symbolic link to the source at the destination
+	OpSymlink = "symlink"
+)
+
+// checkMode fails unless mode is an operation files can be organized or
+// fetched with: OpMove, OpCopy, OpHardlink or OpSymlink
+func checkMode(mode string) error {
+	switch mode {
+	case "", OpMove, OpCopy, OpHardlink, OpSymlink:
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q (want move, copy, hardlink or symlink)", mode)
+}
+
+// keepsSource reports whether op leaves the source in place
+func keepsSource(op string) bool {
+	return op == OpCopy || op == OpHardlink || op == OpSymlink
+}
+
+// Move describes a single file relocation. Size and ModTime record the
+// state of the source when the move was planned.
+type Move struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+	// Op is OpMove (the default when empty), OpCopy, OpHardlink,
+	// OpSymlink or OpTrash
+	Op      string    `json:"op,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mtime,omitzero"`
+}
+
+// Plan lists the folders and moves an operation will perform. Plans can
+// be saved as JSON, reviewed or edited, and applied later with Apply.
+type Plan struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// WorkDir is the directory relative paths in the plan are resolved
+	// against
+	WorkDir string `json:"workdir,omitempty"`
+	// Log is the undo journal the moves are appended to, if any
+	Log string `json:"log,omitempty"`
+	// Kind is the operation kind journaled, KindOrganize when empty
+	Kind    string   `json:"kind,omitempty"`
+	Folders []string `json:"folders"`
+	Moves   []Move   `json:"moves"`
+	// Skipped lists files that were left alone because of an error
+	Skipped []Skip `json:"skipped,omitempty"`
+}
+
+// Skip records a file a plan leaves alone and why
+type Skip struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// MoveError reports a move that could not be performed
+type MoveError struct {
+	Move
+	Err error
+}
+
+func (e *MoveError) Error() string {
+	return fmt.Sprintf("moving %s: %v", e.Source, e.Err)
+}
+
+func (e *MoveError) Unwrap() error {
+	return e.Err
+}
+
+// Result reports what an operation actually did
+type Result struct {
+	Created []string
+	Moved   []Move
+	Failed  []*MoveError
+	// Removed lists directories deleted by a fetch cleanup
+	Removed []string
+	// Errors holds failures that are not tied to a single move
+	Errors []error
+}
+
+// WriteJSON writes the plan as indented JSON
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(p)
+}
+
+// ReadPlan decodes and validates a plan written by WriteJSON
+func ReadPlan(r io.Reader) (*Plan, error) {
+	var p Plan
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadPlan reads a plan from a JSON file
+func LoadPlan(path string) (*Plan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadPlan(f)
+}
+
+// Validate checks that the plan is well formed: every move has a source
+// and a destination, and no two moves share either
+func (p *Plan) Validate() error {
+	if p.Version != PlanVersion {
+		return fmt.Errorf("unsupported plan version %d (want %d)", p.Version, PlanVersion)
+	}
+	switch p.Kind {
+	case "", KindOrganize, KindFetch:
+	default:
+		return fmt.Errorf("unknown plan kind %q", p.Kind)
+	}
+	sources := make(map[string]bool)
+	dests := make(map[string]bool)
+	for i, move := range p.Moves {
+		if move.Source == "" || move.Dest == "" {
+			return fmt.Errorf("move %d: source and destination are required", i+1)
+		}
+		switch move.Op {
+		case "", OpMove, OpCopy, OpHardlink, OpSymlink, OpTrash:
+		default:
+			return fmt.Errorf("move %d: unknown operation %q", i+1, move.Op)
+		}
+		source, dest := filepath.Clean(move.Source), filepath.Clean(move.Dest)
+		if sources[source] {
+			return fmt.Errorf("move %d: %s is moved more than once", i+1, move.Source)
+		}
+		if dests[dest] {
+			return fmt.Errorf("move %d: %s is the destination of more than one move", i+1, move.Dest)
+		}
+		sources[source] = true
+		dests[dest] = true
+	}
+	return nil
+}
+
+// resolve makes a path of the plan usable from the current directory
+func (p *Plan) resolve(path string) string {
+	if p.WorkDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.WorkDir, path)
+}
+
+// Apply performs plan. Each source is checked to still exist and to be
+// unchanged since planning, and each destination to still be free; moves
+// failing these checks are reported in the Result and skipped. Moves are
+// recorded in the plan's undo journal when it names one.
+func Apply(ctx context.Context, plan *Plan) (*Result, error) {
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+	j, err := plan.openJournal()
+	if err != nil {
+		return nil, err
+	}
+	res, err := execute(ctx, plan, j)
+	j.finish(res)
+	return res, err
+}
+
+// openJournal opens the plan's undo journal, or returns nil if the plan
+// is not journaled
+func (p *Plan) openJournal() (*journal, error) {
+	if p.Log == "" {
+		return nil, nil
+	}
+	kind := p.Kind
+	if kind == "" {
+		kind = KindOrganize
+	}
+	j, err := openJournal(p.resolve(p.Log), kind, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	return j, nil
+}
+
+// execute performs plan, recording it in j if not nil
+func execute(ctx context.Context, plan *Plan, j *journal) (*Result, error) {
+	res := &Result{}
+
+	// mkdir creates a folder and journals the directories it made
+	mkdir := func(folder string) error {
+		made, err := mkdirAll(plan.resolve(folder))
+		if err != nil {
+			return fmt.Errorf("cannot create folder %s: %w", folder, err)
+		}
+		for _, dir := range made {
+			if err := j.folder(dir); err != nil {
+				res.Errors = append(res.Errors, fmt.Errorf("could not write journal: %w", err))
+			}
+		}
+		return nil
+	}
+
+	for _, skip := range plan.Skipped {
+		res.Errors = append(res.Errors, fmt.Errorf("skipped %s: %s", skip.Path, skip.Reason))
+	}
+
+	created := make(map[string]bool)
+	failedFolders := make(map[string]error)
+	for _, folder := range plan.Folders {
+		created[folder] = true
+		if err := mkdir(folder); err != nil {
+			failedFolders[folder] = err
+			continue
+		}
+		res.Created = append(res.Created, folder)
+	}
+
+	for _, move := range plan.Moves {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		folder := filepath.Dir(move.Dest)
+		if err, ok := failedFolders[folder]; ok {
+			res.Failed = append(res.Failed, &MoveError{Move: move, Err: err})
+			continue
+		}
+
+		source, dest := plan.resolve(move.Source), plan.resolve(move.Dest)
+		if err := checkMove(move, source, dest); err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: move, Err: err})
+			continue
+		}
+
+		// Edited plans may move files into folders they do not list
+		if !created[folder] && move.Op != OpTrash {
+			created[folder] = true
+			if _, err := os.Stat(plan.resolve(folder)); errors.Is(err, os.ErrNotExist) {
+				if err := mkdir(folder); err != nil {
+					failedFolders[folder] = err
+					res.Failed = append(res.Failed, &MoveError{Move: move, Err: failedFolders[folder]})
+					continue
+				}
+				res.Created = append(res.Created, folder)
+			}
+		}
+
+		crossDevice, err := perform(move.Op, source, dest)
+		if err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: move, Err: err})
+			continue
+		}
+		res.Moved = append(res.Moved, move)
+
+		// Journal the size and time undo verifies as found at the
+		// destination, which may differ after a copy to another filesystem
+		done := Move{Source: source, Dest: dest, Op: move.Op, Size: move.Size, ModTime: move.ModTime}
+		if info, err := os.Lstat(dest); err == nil {
+			done.Size, done.ModTime = info.Size(), info.ModTime()
+		}
+		if err := j.move(done, crossDevice); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("could not journal %s: %w", move.Source, err))
+		}
+	}
+	return res, nil
+}
+
+// checkMove verifies that source is still the file that was planned and
+// that dest is still free
+func checkMove(move Move, source, dest string) error {
+	info, err := os.Lstat(source)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrSourceMissing
+	} else if err != nil {
+		return err
+	}
+	if !move.ModTime.IsZero() && (info.Size() != move.Size || !info.ModTime().Equal(move.ModTime)) {
+		return ErrSourceChanged
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return ErrDestExists
+	}
+	return nil
+}
+
+// planner accumulates a Plan, remembering which destinations are taken
+// so two planned moves never collide
+type planner struct {
+	plan    *Plan
+	folders map[string]bool
+	taken   map[string]bool
+	// counters numbers the files placed into each folder, so files that
+	// are skipped or deduplicated leave no gaps
+	counters map[string]int
+	// onConflict is the policy for destinations that are taken, see
+	// Options.OnConflict
+	onConflict string
+	// planned maps destinations to the index of the move in plan.Moves
+	planned map[string]int
+}
+
+func newPlanner(log string) *planner {
+	plan := &Plan{
+		Version: PlanVersion,
+		Created: time.Now(),
+		Log:     log,
+	}
+	if wd, err := os.Getwd(); err == nil {
+		plan.WorkDir = wd
+	}
+	return &planner{
+		plan:     plan,
+		folders:  make(map[string]bool),
+		taken:    make(map[string]bool),
+		counters: make(map[string]int),
+		planned:  make(map[string]int),
+	}
+}
+
+// add plans move for the file described by info, applying the conflict
+// policy if its destination is taken
+func (p *planner) add(move Move, info os.FileInfo) {
+	if p.resolveConflict(&move, info) {
+		p.place(move, info)
+	}
+}
+
+// place plans move, renaming its destination if it is still taken. The
+// folders of moves to the trash are not planned: they are the user's, and
+// are created when needed but never journaled, so undo leaves them be.
+func (p *planner) place(move Move, info os.FileInfo) {
+	folder := filepath.Dir(move.Dest)
+	if move.Op != OpTrash && !p.folders[folder] {
+		p.folders[folder] = true
+		if _, err := os.Stat(folder); errors.Is(err, os.ErrNotExist) {
+			p.plan.Folders = append(p.plan.Folders, folder)
+		}
+	}
+
+	move.Dest = avoidCollision(move.Dest, p.taken)
+	move.Size = info.Size()
+	move.ModTime = info.ModTime()
+	p.counters[folder]++
+	p.taken[move.Dest] = true
+	p.planned[move.Dest] = len(p.plan.Moves)
+	p.plan.Moves = append(p.plan.Moves, move)
+}
+
+// drop removes the i-th planned move, leaving its source where it is. A
+// folder the plan would create only for this move is no longer created.
+func (p *planner) drop(i int) {
+	dest := p.plan.Moves[i].Dest
+	p.plan.Moves = slices.Delete(p.plan.Moves, i, i+1)
+	delete(p.planned, dest)
+	p.taken[dest] = false
+	for d, j := range p.planned {
+		if j > i {
+			p.planned[d] = j - 1
+		}
+	}
+
+	folder := filepath.Dir(dest)
+	for _, move := range p.plan.Moves {
+		if filepath.Dir(move.Dest) == folder {
+			return
+		}
+	}
+	delete(p.folders, folder)
+	if j := slices.Index(p.plan.Folders, folder); j >= 0 {
+		p.plan.Folders = slices.Delete(p.plan.Folders, j, j+1)
+	}
+}
+
+// skip records that the file at path is left alone
+func (p *planner) skip(path, reason string) {
+	p.plan.Skipped = append(p.plan.Skipped, Skip{Path: path, Reason: reason})
+}
@@ -0,0 +1,83 @@
+package organizer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) os.FileInfo {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestDropForgetsNewFolder(t *testing.T) {
+	dir := t.TempDir()
+	loser := filepath.Join(dir, "a", "x.txt")
+	winner := filepath.Join(dir, "b", "x.txt")
+	loserInfo := writeFile(t, loser, "loser")
+	winnerInfo := writeFile(t, winner, "winner")
+
+	p := newPlanner("")
+	p.onConflict = ConflictOverwrite
+	p.add(Move{Source: loser, Dest: filepath.Join(dir, "new", "x.txt")}, loserInfo)
+	if want := []string{filepath.Join(dir, "new")}; !slices.Equal(p.plan.Folders, want) {
+		t.Fatalf("folders = %v, want %v", p.plan.Folders, want)
+	}
+
+	// The winner replaces the loser's planned move and goes elsewhere
+	p.drop(0)
+	p.add(Move{Source: winner, Dest: filepath.Join(dir, "other", "x.txt")}, winnerInfo)
+	if want := []string{filepath.Join(dir, "other")}; !slices.Equal(p.plan.Folders, want) {
+		t.Errorf("folders = %v, want %v", p.plan.Folders, want)
+	}
+
+	// A folder dropped earlier is planned again when a move needs it
+	p.add(Move{Source: loser, Dest: filepath.Join(dir, "new", "x.txt")}, loserInfo)
+	if want := []string{filepath.Join(dir, "other"), filepath.Join(dir, "new")}; !slices.Equal(p.plan.Folders, want) {
+		t.Errorf("folders = %v, want %v", p.plan.Folders, want)
+	}
+}
+
+func TestOverwritePlannedMoveKeepsOneFolder(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a", "x.txt"), "first")
+	writeFile(t, filepath.Join(dir, "b", "x.txt"), "second")
+
+	org, err := New(Options{Dir: dir, Target: dir, Recursive: true, OnConflict: ConflictOverwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := org.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Moves) != 1 || len(plan.Skipped) != 1 {
+		t.Fatalf("got %d moves and %d skipped files, want 1 and 1", len(plan.Moves), len(plan.Skipped))
+	}
+	if want := []string{filepath.Join(dir, "gorder_txt")}; !slices.Equal(plan.Folders, want) {
+		t.Errorf("folders = %v, want %v", plan.Folders, want)
+	}
+	for _, folder := range plan.Folders {
+		used := false
+		for _, move := range plan.Moves {
+			used = used || filepath.Dir(move.Dest) == folder
+		}
+		if !used {
+			t.Errorf("folder %s is created but no file is moved into it", folder)
+		}
+	}
+}
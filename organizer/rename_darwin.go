@@ -0,0 +1,22 @@
+package organizer
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// renameNoReplace renames source to dest, failing if dest exists, in a
+// single atomic step
+func renameNoReplace(source, dest string) error {
+	err := unix.RenamexNp(source, dest, unix.RENAME_EXCL)
+	// Some filesystems lack RENAME_EXCL
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EINVAL) {
+		return linkRename(source, dest)
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: source, New: dest, Err: err}
+	}
+	return nil
+}
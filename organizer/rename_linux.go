@@ -0,0 +1,22 @@
+package organizer
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// renameNoReplace renames source to dest, failing if dest exists, in a
+// single atomic step
+func renameNoReplace(source, dest string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, source, unix.AT_FDCWD, dest, unix.RENAME_NOREPLACE)
+	// Older kernels and some filesystems lack RENAME_NOREPLACE
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) {
+		return linkRename(source, dest)
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: source, New: dest, Err: err}
+	}
+	return nil
+}
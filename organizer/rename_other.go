@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package organizer
+
+// renameNoReplace renames source to dest, failing if dest exists
+func renameNoReplace(source, dest string) error {
+	return linkRename(source, dest)
+}
@@ -0,0 +1,25 @@
+package organizer
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// renameNoReplace renames source to dest, failing if dest exists, in a
+// single atomic step
+func renameNoReplace(source, dest string) error {
+	from, err := windows.UTF16PtrFromString(source)
+	if err != nil {
+		return err
+	}
+	to, err := windows.UTF16PtrFromString(dest)
+	if err != nil {
+		return err
+	}
+	// Without MOVEFILE_REPLACE_EXISTING an existing dest is an error
+	if err := windows.MoveFileEx(from, to, 0); err != nil {
+		return &os.LinkError{Op: "rename", Old: source, New: dest, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package organizer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReportName is the file the directory report is conventionally written to
+const ReportName = "gorder_report.md"
+
+// FileEntry is a file seen while scanning a directory
+type FileEntry struct {
+	Path string
+	Size int64
+	Ext  string
+}
+
+// ExtStats aggregates the files sharing an extension
+type ExtStats struct {
+	Ext   string
+	Count int
+	Size  int64
+}
+
+// Report summarizes the contents of a directory tree
+type Report struct {
+	// Files is sorted by size, largest first
+	Files []FileEntry
+	// Extensions is sorted by total size, largest first
+	Extensions []*ExtStats
+	TotalSize  int64
+	Dirs       int
+	Hidden     int
+	NoExt      int
+}
+
+// ScanOptions configures Scan
+type ScanOptions struct {
+	// ExcludeDirs prunes matching directories, see Options.ExcludeDirs
+	ExcludeDirs []string
+}
+
+// Scan walks dir and collects the statistics of a Report
+func Scan(ctx context.Context, dir string, opts ScanOptions) (*Report, error) {
+	r := &Report{}
+	extMap := make(map[string]*ExtStats)
+
+	// Walk through all files and directories
+	err := walkTree(ctx, dir, opts.ExcludeDirs, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			r.Dirs++
+			return nil
+		}
+
+		// Track hidden files
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			r.Hidden++
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if ext == "" {
+			r.NoExt++
+			ext = "(no extension)"
+		} else {
+			ext = strings.ToLower(ext)
+		}
+
+		size := info.Size()
+		r.TotalSize += size
+
+		// Track file
+		r.Files = append(r.Files, FileEntry{
+			Path: path,
+			Size: size,
+			Ext:  ext,
+		})
+
+		// Track extension stats
+		if _, ok := extMap[ext]; !ok {
+			extMap[ext] = &ExtStats{Ext: ext}
+		}
+		extMap[ext].Count++
+		extMap[ext].Size += size
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning directory: %w", err)
+	}
+
+	// Sort files by size (largest first)
+	sort.Slice(r.Files, func(i, j int) bool {
+		return r.Files[i].Size > r.Files[j].Size
+	})
+
+	// Sort extensions by total size (for bar chart)
+	for _, stats := range extMap {
+		r.Extensions = append(r.Extensions, stats)
+	}
+	sort.Slice(r.Extensions, func(i, j int) bool {
+		return r.Extensions[i].Size > r.Extensions[j].Size
+	})
+
+	return r, nil
+}
+
+// WriteMarkdown renders the report as Markdown
+func (r *Report) WriteMarkdown(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	// Header
+	fmt.Fprintf(w, "# Gorder Directory Report\n\n")
+	fmt.Fprintf(w, "Generated: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "---\n\n")
+
+	// Directory Summary
+	fmt.Fprintf(w, "## 📊 Directory Summary\n\n")
+	fmt.Fprintf(w, "- **Total Files**: %d\n", len(r.Files))
+	fmt.Fprintf(w, "- **Total Size**: %s\n", FormatSize(r.TotalSize))
+	fmt.Fprintf(w, "- **Total Directories**: %d\n", r.Dirs)
+	fmt.Fprintf(w, "- **Hidden Files**: %d (skipped from analysis)\n", r.Hidden)
+	fmt.Fprintf(w, "- **Files Without Extension**: %d\n\n", r.NoExt)
+
+	// File Type Distribution
+	fmt.Fprintf(w, "## 📁 File Type Distribution\n\n")
+	fmt.Fprintf(w, "| Extension | Count | Total Size |\n")
+	fmt.Fprintf(w, "|-----------|-------|------------|\n")
+	for _, entry := range r.Extensions {
+		fmt.Fprintf(w, "| %s | %d | %s |\n", entry.Ext, entry.Count, FormatSize(entry.Size))
+	}
+	fmt.Fprintf(w, "\n")
+
+	// Top 5 File Types Bar Chart
+	if len(r.Extensions) > 0 {
+		fmt.Fprintf(w, "## 📈 Top 5 File Types (by size)\n\n")
+		maxWidth := 50
+		topN := 5
+		if len(r.Extensions) < topN {
+			topN = len(r.Extensions)
+		}
+		maxSize := r.Extensions[0].Size
+		for i := 0; i < topN; i++ {
+			entry := r.Extensions[i]
+			barWidth := 0
+			if maxSize > 0 {
+				barWidth = int(float64(entry.Size) / float64(maxSize) * float64(maxWidth))
+			}
+			if barWidth == 0 && entry.Size > 0 {
+				barWidth = 1
+			}
+			bar := strings.Repeat("█", barWidth)
+			fmt.Fprintf(w, "%15s | %-50s %s\n", entry.Ext, bar, FormatSize(entry.Size))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	// Top 10 Largest Files
+	fmt.Fprintf(w, "## 📦 Top 10 Largest Files\n\n")
+	fmt.Fprintf(w, "| # | File Path | Size |\n")
+	fmt.Fprintf(w, "|---|-----------|------|\n")
+	topFiles := 10
+	if len(r.Files) < topFiles {
+		topFiles = len(r.Files)
+	}
+	for i := 0; i < topFiles; i++ {
+		file := r.Files[i]
+		fmt.Fprintf(w, "| %d | %s | %s |\n", i+1, file.Path, FormatSize(file.Size))
+	}
+
+	return w.Flush()
+}
+
+// FormatSize renders a byte count with a binary unit suffix
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,356 @@
+package organizer
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Rule actions
+const (
+	ActionMove   = "move"
+	ActionCopy   = "copy"
+	ActionSkip   = "skip"
+	ActionTrash  = "trash"
+	ActionRename = "rename"
+)
+
+// Rule routes the files matching all of its conditions. Rules are tried
+// in order and the first match wins; files matching no rule are grouped
+// by the organizer's strategy.
+type Rule struct {
+	Name string `toml:"name" yaml:"name" json:"name"`
+
+	// Glob matches the file name, or the path relative to the organized
+	// directory when it contains a slash; "**" spans directories
+	Glob string `toml:"glob" yaml:"glob" json:"glob"`
+	// Regex matches the path relative to the organized directory
+	Regex string `toml:"regex" yaml:"regex" json:"regex"`
+	// PathPrefix matches the path relative to the organized directory
+	PathPrefix string `toml:"path_prefix" yaml:"path_prefix" json:"path_prefix"`
+	// MinSize and MaxSize bound the file size, e.g. "10MB" (inclusive)
+	MinSize string `toml:"min_size" yaml:"min_size" json:"min_size"`
+	MaxSize string `toml:"max_size" yaml:"max_size" json:"max_size"`
+	// OlderThan and NewerThan bound the age of the modification time,
+	// e.g. "30d", "2w" or "12h"
+	OlderThan string `toml:"older_than" yaml:"older_than" json:"older_than"`
+	NewerThan string `toml:"newer_than" yaml:"newer_than" json:"newer_than"`
+	// MIME matches the content type, e.g. "image/*" or "application/pdf"
+	MIME string `toml:"mime" yaml:"mime" json:"mime"`
+
+	// Action is move (default), copy, skip, trash or rename
+	Action string `toml:"action" yaml:"action" json:"action"`
+	// To is the layout template of the destination for move and copy
+	// (the organizer's strategy when empty), or the file name template
+	// for rename
+	To string `toml:"to" yaml:"to" json:"to"`
+
+	re        *regexp.Regexp
+	minSize   int64
+	maxSize   int64
+	olderThan time.Duration
+	newerThan time.Duration
+	layout    *Layout
+}
+
+// label names the rule in explanations
+func (r *Rule) label(i int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("rule %d (%s)", i+1, r.Name)
+	}
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+// compile parses the conditions and destination of the rule
+func (r *Rule) compile(opts Options) error {
+	var err error
+	if r.Action == "" {
+		r.Action = ActionMove
+	}
+	switch r.Action {
+	case ActionMove, ActionCopy, ActionSkip, ActionTrash, ActionRename:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+
+	if r.Glob != "" {
+		if err := validGlob(r.Glob); err != nil {
+			return err
+		}
+	}
+	if r.Regex != "" {
+		if r.re, err = regexp.Compile(r.Regex); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", r.Regex, err)
+		}
+	}
+	if r.MinSize != "" {
+		if r.minSize, err = ParseSize(r.MinSize); err != nil {
+			return err
+		}
+	}
+	if r.MaxSize != "" {
+		if r.maxSize, err = ParseSize(r.MaxSize); err != nil {
+			return err
+		}
+	}
+	if r.OlderThan != "" {
+		if r.olderThan, err = ParseAge(r.OlderThan); err != nil {
+			return err
+		}
+	}
+	if r.NewerThan != "" {
+		if r.newerThan, err = ParseAge(r.NewerThan); err != nil {
+			return err
+		}
+	}
+	if r.MIME != "" && !strings.Contains(r.MIME, "/") {
+		return fmt.Errorf("invalid MIME type %q", r.MIME)
+	}
+
+	switch r.Action {
+	case ActionMove, ActionCopy:
+		if r.To != "" {
+			if r.layout, err = ParseLayout(r.To, opts); err != nil {
+				return err
+			}
+		}
+	case ActionRename:
+		if r.To == "" {
+			return fmt.Errorf("rename needs a file name template in \"to\"")
+		}
+		if r.layout, err = ParseLayout(r.To, opts); err != nil {
+			return err
+		}
+		if len(r.layout.dirs) > 0 || r.layout.file == nil {
+			return fmt.Errorf("rename template %q must be a single file name using {name}, {stem} or {counter}", r.To)
+		}
+	}
+	return nil
+}
+
+// match checks every condition of the rule against entry. rel is the
+// slash-separated path of the entry relative to the organized directory.
+// It returns whether all conditions hold and a line per checked condition.
+func (r *Rule) match(entry Entry, rel string, now time.Time) (bool, []string) {
+	var checks []string
+	ok := true
+	check := func(cond bool, format string, args ...any) {
+		mark := "✓"
+		if !cond {
+			mark = "✗"
+			ok = false
+		}
+		checks = append(checks, mark+" "+fmt.Sprintf(format, args...))
+	}
+
+	if r.Glob != "" {
+		subject := entry.Name()
+		if strings.Contains(r.Glob, "/") {
+			subject = rel
+		}
+		check(matchGlob(r.Glob, entry.Name(), rel), "glob %q against %q", r.Glob, subject)
+	}
+	if r.re != nil {
+		check(r.re.MatchString(rel), "regex %q against %q", r.Regex, rel)
+	}
+	if r.PathPrefix != "" {
+		prefix := filepath.ToSlash(r.PathPrefix)
+		check(strings.HasPrefix(rel, prefix), "path %q starts with %q", rel, prefix)
+	}
+	size := entry.Info.Size()
+	if r.MinSize != "" {
+		check(size >= r.minSize, "size %s ≥ %s", FormatSize(size), r.MinSize)
+	}
+	if r.MaxSize != "" {
+		check(size <= r.maxSize, "size %s ≤ %s", FormatSize(size), r.MaxSize)
+	}
+	age := now.Sub(entry.Info.ModTime())
+	if r.OlderThan != "" {
+		check(age >= r.olderThan, "age %s older than %s", formatAge(age), r.OlderThan)
+	}
+	if r.NewerThan != "" {
+		check(age <= r.newerThan, "age %s newer than %s", formatAge(age), r.NewerThan)
+	}
+	if r.MIME != "" {
+		mimeType := detectMIME(entry.Path)
+		check(matchMIME(r.MIME, mimeType), "type %s matches %s", mimeType, r.MIME)
+	}
+	if len(checks) == 0 {
+		checks = append(checks, "✓ no conditions, matches every file")
+	}
+	return ok, checks
+}
+
+// matchMIME matches a content type against a pattern such as "image/*"
+func matchMIME(pattern, mimeType string) bool {
+	pattern = strings.ToLower(pattern)
+	if major, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mimeType, major+"/")
+	}
+	return mimeType == pattern
+}
+
+// detectMIME sniffs the content type of a file, falling back to its
+// extension when the content is not conclusive
+func detectMIME(path string) string {
+	byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+
+	sniffed := "application/octet-stream"
+	if f, err := os.Open(path); err == nil {
+		buf := make([]byte, 512)
+		n, _ := f.Read(buf)
+		f.Close()
+		if n > 0 {
+			sniffed = http.DetectContentType(buf[:n])
+		}
+	}
+
+	if byExt != "" && (strings.HasPrefix(sniffed, "application/octet-stream") || strings.HasPrefix(sniffed, "text/plain")) {
+		sniffed = byExt
+	}
+	mediaType, _, err := mime.ParseMediaType(sniffed)
+	if err != nil {
+		return sniffed
+	}
+	return mediaType
+}
+
+// ParseSize parses a size such as "512", "10KB", "1.5 GiB". Units are
+// powers of 1024, matching FormatSize.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsSpace(r) })
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+	exp := strings.Index("KMGTPE", unit)
+	switch {
+	case unit == "":
+		exp = -1
+	case len(unit) != 1 || exp < 0:
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+	for ; exp >= 0; exp-- {
+		n *= 1024
+	}
+	return int64(n), nil
+}
+
+// ParseAge parses an age such as "30d", "2w", "1y" or any
+// time.ParseDuration value
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'y': 365 * 24 * time.Hour}
+	if len(s) > 1 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+			if err == nil && n >= 0 {
+				return time.Duration(n * float64(unit)), nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// formatAge renders an age in days, or hours when under two days
+func formatAge(d time.Duration) string {
+	if d < 48*time.Hour {
+		return fmt.Sprintf("%.0fh", d.Hours())
+	}
+	return fmt.Sprintf("%.0fd", d.Hours()/24)
+}
+
+// Explanation tells which rule routes a file and why
+type Explanation struct {
+	Path string
+	// Rule is the matching rule, or nil if the strategy applies
+	Rule *Rule
+	// Checks holds, per rule tried, its label and condition results
+	Checks [][]string
+	// Move is the planned move, or nil if the file is left alone
+	Move *Move
+	// Filtered tells why the file is left alone before any rule is tried,
+	// e.g. an exclude entry matching it. Rule and Checks are then empty.
+	Filtered string
+}
+
+// Explain reports how the organizer would route the file at path: every
+// rule tried with its condition results, the matching rule, and the
+// resulting move
+func (o *Organizer) Explain(path string) (*Explanation, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	ex := &Explanation{Path: path}
+	// Rules are only tried on files plan does not filter out
+	if isGorderFile(info.Name()) {
+		ex.Filtered = "gorder's own file"
+		return ex, nil
+	}
+	if ex.Filtered = o.filter.rejects(info.Name(), o.relPath(path)); ex.Filtered != "" {
+		return ex, nil
+	}
+
+	entry := newEntry(path, info, o.dateSettings)
+	p := newPlanner("")
+	now := p.plan.Created
+	for i, rule := range o.rules {
+		ok, checks := rule.match(entry, o.relPath(path), now)
+		ex.Checks = append(ex.Checks, append([]string{rule.label(i)}, checks...))
+		if ok {
+			ex.Rule = rule
+			break
+		}
+	}
+
+	o.plan(p, path, info)
+	if len(p.plan.Moves) > 0 {
+		ex.Move = &p.plan.Moves[0]
+	}
+	return ex, nil
+}
+
+// relPath returns path relative to the organized directory with forward
+// slashes
+func (o *Organizer) relPath(p string) string {
+	rel, err := filepath.Rel(o.opts.Dir, p)
+	if err != nil {
+		rel = p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// matchRule returns the first rule matching entry and its index. Age
+// conditions are measured back from now.
+func (o *Organizer) matchRule(entry Entry, now time.Time) (*Rule, int) {
+	rel := o.relPath(entry.Path)
+	for i, rule := range o.rules {
+		if ok, _ := rule.match(entry, rel, now); ok {
+			return rule, i
+		}
+	}
+	return nil, -1
+}
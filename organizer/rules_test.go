@@ -0,0 +1,125 @@
+package organizer
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExplainFilteredFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.tmp", "b.txt", "c.jpg", ".hidden.txt"} {
+		writeFile(t, filepath.Join(dir, name), name)
+	}
+	org, err := New(Options{
+		Dir:     dir,
+		Target:  dir,
+		Include: []string{"tmp", "jpg"},
+		Exclude: []string{"*.tmp"},
+		Rules:   []Rule{{Glob: "*", Action: ActionSkip}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filtered string
+	}{
+		{"a.tmp", `excluded by --exclude "*.tmp"`},
+		{"b.txt", "not matched by --include"},
+		{".hidden.txt", "hidden file"},
+		{JournalName, "gorder's own file"},
+		{"c.jpg", ""},
+	}
+	writeFile(t, filepath.Join(dir, JournalName), "")
+	for _, tt := range tests {
+		ex, err := org.Explain(filepath.Join(dir, tt.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ex.Filtered != tt.filtered {
+			t.Errorf("%s: Filtered = %q, want %q", tt.name, ex.Filtered, tt.filtered)
+		}
+		if matched := ex.Rule != nil; matched == (tt.filtered != "") {
+			t.Errorf("%s: rule matched = %v with Filtered %q", tt.name, matched, ex.Filtered)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{in: "0", want: 0},
+		{in: "512", want: 512},
+		{in: " 512 ", want: 512},
+		{in: "10KB", want: 10 << 10},
+		{in: "10kb", want: 10 << 10},
+		{in: "10K", want: 10 << 10},
+		{in: "10KiB", want: 10 << 10},
+		{in: "1.5 GiB", want: 3 << 29},
+		{in: "2MB", want: 2 << 20},
+		{in: "1T", want: 1 << 40},
+		{in: "1EB", want: 1 << 60},
+		{in: "100B", want: 100},
+
+		{in: "", err: true},
+		{in: "KB", err: true},
+		{in: "-1KB", err: true},
+		{in: "10XB", err: true},
+		{in: "10 KMB", err: true},
+		{in: "ten", err: true},
+		{in: "1.2.3MB", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("ParseSize(%q) = %d, want an error", tt.in, got)
+		case !tt.err && err != nil:
+			t.Errorf("ParseSize(%q): %v", tt.in, err)
+		case got != tt.want:
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "30d", want: 30 * day},
+		{in: " 2w ", want: 14 * day},
+		{in: "1y", want: 365 * day},
+		{in: "1.5d", want: 36 * time.Hour},
+		{in: "0d", want: 0},
+		{in: "12h", want: 12 * time.Hour},
+		{in: "90m", want: 90 * time.Minute},
+		{in: "1h30m", want: 90 * time.Minute},
+
+		{in: "", err: true},
+		{in: "d", err: true},
+		{in: "-1d", err: true},
+		{in: "-5h", err: true},
+		{in: "30", err: true},
+		{in: "3x", err: true},
+		{in: "twod", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("ParseAge(%q) = %v, want an error", tt.in, got)
+		case !tt.err && err != nil:
+			t.Errorf("ParseAge(%q): %v", tt.in, err)
+		case got != tt.want:
+			t.Errorf("ParseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
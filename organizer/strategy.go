@@ -0,0 +1,277 @@
+package organizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a file being considered for organizing
+type Entry struct {
+	// Path is the path of the file as found while walking
+	Path string
+	Info os.FileInfo
+
+	// dates caches the dates read by Date, by source
+	dates map[string]entryDate
+	// dateSettings tell how Date reads dates, nil for the defaults
+	dateSettings *dateSettings
+}
+
+// newEntry returns the entry for the file at path
+func newEntry(path string, info os.FileInfo, settings *dateSettings) Entry {
+	return Entry{Path: path, Info: info, dates: make(map[string]entryDate), dateSettings: settings}
+}
+
+// Name returns the base name of the file
+func (e Entry) Name() string {
+	return e.Info.Name()
+}
+
+// Strategy decides which folder a file is grouped into
+type Strategy interface {
+	// Name identifies the strategy in plans and error messages
+	Name() string
+	// Destination returns the folder, relative to the target directory,
+	// that entry belongs to, or false if the entry should be left alone
+	Destination(entry Entry) (string, bool)
+}
+
+// StrategyFactory builds a Strategy configured from opts
+type StrategyFactory func(opts Options) (Strategy, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]StrategyFactory)
+)
+
+func init() {
+	RegisterStrategy("extension", func(opts Options) (Strategy, error) {
+		return &ExtensionStrategy{
+			FullExt:       opts.FullExt,
+			CaseSensitive: opts.CaseSensitive,
+			NoExtFolder:   opts.NoExtFolder,
+			Quiet:         opts.Quiet,
+		}, nil
+	})
+	RegisterStrategy("category", func(opts Options) (Strategy, error) {
+		categories, err := CategoryIndex(categoryMap(opts))
+		if err != nil {
+			return nil, err
+		}
+		return &CategoryStrategy{
+			ExtensionStrategy: ExtensionStrategy{
+				FullExt:       opts.FullExt,
+				CaseSensitive: opts.CaseSensitive,
+				NoExtFolder:   opts.NoExtFolder,
+				Quiet:         true,
+			},
+			Categories: categories,
+		}, nil
+	})
+	RegisterStrategy("date", func(opts Options) (Strategy, error) {
+		mode := opts.DateMode
+		if mode == "" {
+			mode = DateModeMonth
+		}
+		if err := checkDateMode(mode); err != nil {
+			return nil, err
+		}
+		if opts.DateFormat != "" {
+			if err := checkDateFormat(opts.DateFormat); err != nil {
+				return nil, err
+			}
+		}
+		return &DateStrategy{
+			Mode:        mode,
+			Format:      opts.DateFormat,
+			FiscalStart: opts.FiscalYearStart,
+			Source:      opts.DateSource,
+		}, nil
+	})
+}
+
+// RegisterStrategy makes a strategy available under name, replacing any
+// strategy previously registered with that name
+func RegisterStrategy(name string, factory StrategyFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Strategies returns the names of the registered strategies
+func Strategies() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategy builds the strategy registered under name. Several names
+// separated by commas or slashes are composed into a Chain.
+func NewStrategy(name string, opts Options) (Strategy, error) {
+	names := strings.FieldsFunc(name, func(r rune) bool { return r == ',' || r == '/' })
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no strategy given")
+	}
+
+	var chain Chain
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		registryMu.RLock()
+		factory, ok := registry[n]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown strategy %q (available: %s)", n, strings.Join(Strategies(), ", "))
+		}
+		s, err := factory(opts)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", n, err)
+		}
+		chain = append(chain, s)
+	}
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// Chain composes strategies into nested folders, e.g. a category and a
+// date strategy produce Images/2024-05
+type Chain []Strategy
+
+// Name returns the names of the chained strategies joined by "+"
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, s := range c {
+		names[i] = s.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+// Destination joins the folders of every strategy in the chain. The file
+// is left alone if any of them declines it.
+func (c Chain) Destination(entry Entry) (string, bool) {
+	parts := make([]string, 0, len(c))
+	for _, s := range c {
+		folder, ok := s.Destination(entry)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, folder)
+	}
+	return filepath.Join(parts...), true
+}
+
+// ExtensionStrategy groups files into one folder per extension
+type ExtensionStrategy struct {
+	// FullExt uses the last two extensions (e.g. tar.gz) instead of one
+	FullExt bool
+	// CaseSensitive keeps the case of extensions (JPG vs jpg)
+	CaseSensitive bool
+	// NoExtFolder receives files without an extension; they are skipped if empty
+	NoExtFolder string
+	// Quiet drops the gorder_ prefix from folder names
+	Quiet bool
+}
+
+// Name returns "extension"
+func (s *ExtensionStrategy) Name() string {
+	return "extension"
+}
+
+// Destination returns gorder_<ext>, or <ext> in quiet mode
+func (s *ExtensionStrategy) Destination(entry Entry) (string, bool) {
+	ext, ok := s.extension(entry)
+	if !ok || ext == "" {
+		return s.NoExtFolder, ok
+	}
+	if s.Quiet {
+		return ext, true
+	}
+	return fmt.Sprintf("gorder_%s", ext), true
+}
+
+// extension returns the normalized extension of entry. An empty
+// extension is only reported if NoExtFolder is set.
+func (s *ExtensionStrategy) extension(entry Entry) (string, bool) {
+	ext := getExtension(entry.Name(), s.FullExt)
+
+	// Handle files without extension
+	if ext == "" {
+		return "", s.NoExtFolder != ""
+	}
+
+	if !s.CaseSensitive {
+		ext = strings.ToLower(ext)
+	}
+	return ext, true
+}
+
+// CategoryStrategy groups files by the category of their extension,
+// falling back to the bare extension for unknown ones
+type CategoryStrategy struct {
+	ExtensionStrategy
+	// Categories maps lower-case extensions to category names
+	Categories map[string]string
+}
+
+// Name returns "category"
+func (s *CategoryStrategy) Name() string {
+	return "category"
+}
+
+// Destination returns the category of the file's extension
+func (s *CategoryStrategy) Destination(entry Entry) (string, bool) {
+	ext, ok := s.extension(entry)
+	if !ok || ext == "" {
+		return s.NoExtFolder, ok
+	}
+	if cat, ok := s.Categories[strings.ToLower(ext)]; ok {
+		return cat, true
+	}
+	return ext, true
+}
+
+// DateStrategy groups files by date
+type DateStrategy struct {
+	// Mode is one of the DateMode constants, or several nested with
+	// slashes such as "year/month"
+	Mode string
+	// Format, a Go layout such as "2006/01" or a strftime format such as
+	// "%Y/Q%q", names the folders instead of Mode when set. Slashes
+	// separate nested folders.
+	Format string
+	// FiscalStart is the first month of the fiscal year for the
+	// fiscal-year mode (January when zero)
+	FiscalStart time.Month
+	// Source is where the date is read from, see Entry.Date
+	Source string
+}
+
+// Name returns "date"
+func (s *DateStrategy) Name() string {
+	return "date"
+}
+
+// Destination returns the date folder of the file's date. Files are left
+// alone if Mode is unknown.
+func (s *DateStrategy) Destination(entry Entry) (string, bool) {
+	date, _ := entry.Date(s.Source)
+	if s.Format == "" {
+		return getDateFolder(date, s.Mode, s.FiscalStart)
+	}
+	segments := strings.Split(formatDate(date, s.Format), "/")
+	for i, segment := range segments {
+		segments[i] = sanitizeSegment(segment)
+	}
+	return filepath.Join(segments...), true
+}
@@ -0,0 +1,88 @@
+package organizer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// trashFilesDir returns the directory trashed files are moved to: the
+// freedesktop.org trash in $XDG_DATA_HOME, or ~/.Trash on macOS
+func trashFilesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, ".Trash"), nil
+	case "windows", "plan9":
+		return "", fmt.Errorf("moving to the trash is not supported on %s", runtime.GOOS)
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash", "files"), nil
+}
+
+// trashFile moves source to dest in the trash, creating the trash
+// directories if needed. They belong to the user's desktop and are never
+// journaled, so undo does not remove them.
+func trashFile(source, dest string) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return false, err
+	}
+	if err := writeTrashInfo(source, dest); err != nil {
+		return false, err
+	}
+	crossDevice, err := moveFile(source, dest, false)
+	if err != nil {
+		removeTrashInfo(dest)
+	}
+	return crossDevice, err
+}
+
+// writeTrashInfo records where a file moved to dest in a freedesktop.org
+// trash came from, so file managers can restore it
+func writeTrashInfo(source, dest string) error {
+	filesDir := filepath.Dir(dest)
+	if filepath.Base(filesDir) != "files" {
+		return nil
+	}
+	infoDir := filepath.Join(filepath.Dir(filesDir), "info")
+	if err := os.MkdirAll(infoDir, 0700); err != nil {
+		return err
+	}
+
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(infoDir, filepath.Base(dest)+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("trash already holds an entry named %s", filepath.Base(dest))
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	u := url.URL{Path: abs}
+	_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", u.EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	return err
+}
+
+// removeTrashInfo removes the .trashinfo of a file that was restored from
+// the trash location dest
+func removeTrashInfo(dest string) {
+	filesDir := filepath.Dir(dest)
+	if filepath.Base(filesDir) != "files" {
+		return
+	}
+	os.Remove(filepath.Join(filepath.Dir(filesDir), "info", filepath.Base(dest)+".trashinfo"))
+}
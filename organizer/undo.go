@@ -0,0 +1,496 @@
+package organizer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoLog is returned by Undo when there is no previous operation to undo
+var ErrNoLog = errors.New("no previous operation to undo")
+
+// ErrNoRedo is returned by Redo when there is no undo to redo
+var ErrNoRedo = errors.New("no undone operation to redo")
+
+// ErrLaterOperations is returned by Undo and Redo when operations
+// performed afterwards touched the same files
+var ErrLaterOperations = errors.New("later operations touched the same files")
+
+// ErrFileChanged is reported for files whose size or modification time
+// no longer match the ones journaled when they were moved
+var ErrFileChanged = errors.New("file changed since it was moved")
+
+// ErrCopyChanged is reported for copies and links that no longer match
+// their original, which undo leaves in place even when forced
+var ErrCopyChanged = errors.New("no longer matches the original")
+
+// UndoOptions configures Undo and Redo
+type UndoOptions struct {
+	// ID selects the operation by ID or unique ID prefix. When empty, the
+	// latest operation that can be undone (or redone) is used.
+	ID string
+	// Force proceeds even if later operations touched the same files, and
+	// restores files that changed since they were moved
+	Force bool
+	// OnConflict decides what happens when the original location of a
+	// file is taken: ConflictSkip (the default), ConflictRename,
+	// ConflictOverwrite or ConflictPrompt
+	OnConflict string
+	// Prompt is asked, with the move about to be reverted, which of skip,
+	// rename or overwrite to apply when OnConflict is ConflictPrompt. The
+	// file is skipped if Prompt is nil.
+	Prompt func(move Move) string
+}
+
+// Undo reverts an operation recorded in dir's journal, by default the
+// latest one that has not been undone yet. Directories organized by older
+// versions of gorder are restored from their pipe-separated LogName once
+// the journal has nothing left to undo.
+func Undo(ctx context.Context, dir string, opts UndoOptions) (*Result, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictSkip
+	}
+	if err := checkConflictPolicy(opts.OnConflict, ConflictSkip, ConflictRename, ConflictOverwrite, ConflictPrompt); err != nil {
+		return nil, err
+	}
+	journalPath := filepath.Join(dir, JournalName)
+	ops, err := History(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := findOperation(ops, opts.ID, func(op *Operation) bool {
+		return op.Kind != KindUndo && !op.Undone()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if op == nil {
+		if opts.ID != "" {
+			return nil, fmt.Errorf("no operation %q in the journal", opts.ID)
+		}
+		return undoLegacy(ctx, dir)
+	}
+	switch {
+	case op.Kind == KindUndo:
+		return nil, fmt.Errorf("operation %s is an undo, use redo to revert it", op.ID)
+	case op.Undone():
+		return nil, fmt.Errorf("operation %s was already undone by %s", op.ID, op.RevertedBy)
+	}
+	if err := checkLater(ops, op, opts.Force); err != nil {
+		return nil, err
+	}
+	return undoOperation(ctx, journalPath, op, opts)
+}
+
+// Redo performs again the moves reverted by an undo recorded in dir's
+// journal, by default the latest undo that has not been redone yet
+func Redo(ctx context.Context, dir string, opts UndoOptions) (*Result, error) {
+	ops, err := History(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	undo, err := findOperation(ops, opts.ID, func(op *Operation) bool {
+		return op.Kind == KindUndo && !op.Undone()
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case undo == nil && opts.ID != "":
+		return nil, fmt.Errorf("no operation %q in the journal", opts.ID)
+	case undo == nil:
+		return nil, ErrNoRedo
+	case undo.Kind != KindUndo:
+		return nil, fmt.Errorf("operation %s is not an undo", undo.ID)
+	case undo.Undone():
+		return nil, fmt.Errorf("undo %s was already redone by %s", undo.ID, undo.RevertedBy)
+	}
+	if err := checkLater(ops, undo, opts.Force); err != nil {
+		return nil, err
+	}
+	return redoOperation(ctx, filepath.Join(dir, JournalName), undo)
+}
+
+// findOperation returns the operation whose ID is id or starts with id,
+// or, when id is empty, the latest operation accepted by latest
+func findOperation(ops []*Operation, id string, latest func(*Operation) bool) (*Operation, error) {
+	if id == "" {
+		for i := len(ops) - 1; i >= 0; i-- {
+			if latest(ops[i]) {
+				return ops[i], nil
+			}
+		}
+		return nil, nil
+	}
+
+	var found *Operation
+	for _, op := range ops {
+		if op.ID == id {
+			return op, nil
+		}
+		if strings.HasPrefix(op.ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("operation ID %q is ambiguous", id)
+			}
+			found = op
+		}
+	}
+	return found, nil
+}
+
+// checkLater fails if operations performed after op, and still in
+// effect, moved any of the files op moved
+func checkLater(ops []*Operation, op *Operation, force bool) error {
+	paths := make(map[string]bool)
+	for _, m := range op.Pending() {
+		paths[m.Source] = true
+		paths[m.Dest] = true
+	}
+
+	var later []string
+	after := false
+	for _, other := range ops {
+		if other == op {
+			after = true
+			continue
+		}
+		// Undos cancel out operations, which are then no longer in effect
+		if !after || other.Kind == KindUndo || other.Undone() {
+			continue
+		}
+		for _, m := range other.Pending() {
+			if paths[m.Source] || paths[m.Dest] {
+				later = append(later, other.ID)
+				break
+			}
+		}
+	}
+	if len(later) > 0 && !force {
+		return fmt.Errorf("%w: %s (undo them first or force)", ErrLaterOperations, strings.Join(later, ", "))
+	}
+	return nil
+}
+
+// undoOperation reverses the moves of op that are still in effect,
+// latest first, removes the folders op created once they are empty, and
+// journals what it reverted as an undo operation
+func undoOperation(ctx context.Context, journalPath string, op *Operation, opts UndoOptions) (*Result, error) {
+	j, err := openJournal(journalPath, KindUndo, op.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	res := &Result{}
+	defer j.finish(res)
+
+	// Recreate removed directories, shallowest first, writable until the
+	// files are back in them
+	for i := len(op.Removed) - 1; i >= 0; i-- {
+		dir := op.Removed[i]
+		if err := os.Mkdir(dir.Path, 0700); errors.Is(err, os.ErrExist) {
+			continue
+		} else if err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("cannot recreate directory %s: %w", dir.Path, err))
+			continue
+		}
+		res.Created = append(res.Created, dir.Path)
+		if err := j.folder(dir.Path); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("could not write journal: %w", err))
+		}
+	}
+	defer func() {
+		for _, dir := range op.Removed {
+			if err := os.Chmod(dir.Path, dir.Mode.Perm()); err != nil {
+				res.Errors = append(res.Errors, fmt.Errorf("cannot restore permissions of %s: %w", dir.Path, err))
+				continue
+			}
+			os.Chtimes(dir.Path, dir.ModTime, dir.ModTime)
+		}
+	}()
+
+	moves := op.Pending()
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		move := moves[i]
+		// A copy or hard link whose original is gone is the only one
+		// left, so it is moved back in its place rather than removed
+		if move.Op == OpCopy || move.Op == OpHardlink {
+			if _, err := os.Lstat(move.Source); errors.Is(err, os.ErrNotExist) {
+				move.Op = OpMove
+			}
+		}
+		back := Move{Source: move.Dest, Dest: move.Source, Op: move.Op, Size: move.Size, ModTime: move.ModTime}
+
+		err := verifyMoved(move)
+		changed := errors.Is(err, ErrFileChanged) && opts.Force
+		if err != nil && !changed {
+			res.Failed = append(res.Failed, &MoveError{Move: back, Err: err})
+			continue
+		}
+
+		restored, crossDevice, err := revert(move, opts, res, j)
+		if err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: back, Err: err})
+			continue
+		}
+		if changed {
+			res.Errors = append(res.Errors, fmt.Errorf("%s changed since it was moved, restored anyway", move.Dest))
+		}
+		back.Dest = restored
+		res.Moved = append(res.Moved, back)
+		// Journal where the file went back to, so redo moves it from there
+		move.Source = restored
+		if err := j.move(move, crossDevice); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("could not journal %s: %w", move.Dest, err))
+		}
+	}
+
+	removeCreatedFolders(op.Folders, res, j)
+	return res, nil
+}
+
+// verifyMoved checks that the file a journaled move put in place is still
+// there and unchanged
+func verifyMoved(move Move) error {
+	info, err := os.Lstat(move.Dest)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s no longer exists", move.Dest)
+	} else if err != nil {
+		return err
+	}
+	// A hard link changes along with its original, which unlinkCopy checks
+	if move.Op == OpHardlink {
+		return nil
+	}
+	if !move.ModTime.IsZero() && (info.Size() != move.Size || !info.ModTime().Equal(move.ModTime)) {
+		return ErrFileChanged
+	}
+	return nil
+}
+
+// unlinkCopy removes the copy or link a journaled move created, after
+// checking that it still matches its original
+func unlinkCopy(move Move) error {
+	switch move.Op {
+	case OpCopy:
+		same, err := sameContent(move.Source, move.Dest)
+		if err != nil {
+			return err
+		}
+		if !same {
+			return ErrCopyChanged
+		}
+	case OpHardlink:
+		orig, err := os.Lstat(move.Source)
+		if err != nil {
+			return err
+		}
+		link, err := os.Lstat(move.Dest)
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(orig, link) {
+			return ErrCopyChanged
+		}
+	case OpSymlink:
+		target, err := os.Readlink(move.Dest)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCopyChanged, err)
+		}
+		if source, err := filepath.Abs(move.Source); err != nil || filepath.Clean(target) != source {
+			return ErrCopyChanged
+		}
+	}
+	return os.Remove(move.Dest)
+}
+
+// revert undoes a single journaled move, applying the conflict policy of
+// opts if the original location is taken. It returns where the file was
+// restored to and whether it was copied back from another filesystem.
+func revert(move Move, opts UndoOptions, res *Result, j *journal) (string, bool, error) {
+	if keepsSource(move.Op) {
+		return move.Source, false, unlinkCopy(move)
+	}
+	if move.Op != "" && move.Op != OpMove && move.Op != OpTrash {
+		return "", false, fmt.Errorf("unknown operation %q", move.Op)
+	}
+
+	target, replace := move.Source, false
+	if _, err := os.Lstat(target); err == nil {
+		policy := opts.OnConflict
+		if policy == ConflictPrompt {
+			policy = ConflictSkip
+			if opts.Prompt != nil {
+				policy = opts.Prompt(Move{Source: move.Dest, Dest: move.Source, Op: move.Op})
+			}
+		}
+		switch policy {
+		case ConflictRename:
+			target = avoidCollision(target, nil)
+		case ConflictOverwrite:
+			replace = true
+		default:
+			return "", false, ErrDestExists
+		}
+	}
+
+	// The original folder may have been removed since
+	made, err := mkdirAll(filepath.Dir(target))
+	if err != nil {
+		return "", false, err
+	}
+	for _, dir := range made {
+		res.Created = append(res.Created, dir)
+		if err := j.folder(dir); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("could not write journal: %w", err))
+		}
+	}
+
+	crossDevice, err := moveFile(move.Dest, target, replace)
+	if err != nil {
+		return "", false, err
+	}
+	if move.Op == OpTrash {
+		removeTrashInfo(move.Dest)
+	}
+	return target, crossDevice, nil
+}
+
+// removeCreatedFolders removes the folders in folders, listed parents
+// first, that are empty, and journals them in j
+func removeCreatedFolders(folders []string, res *Result, j *journal) {
+	for i := len(folders) - 1; i >= 0; i-- {
+		dir := folders[i]
+		info, err := os.Lstat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := os.Remove(dir); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("error removing empty directory %s: %w", dir, err))
+			continue
+		}
+		res.Removed = append(res.Removed, dir)
+		if err := j.removed(RemovedDir{Path: dir, Mode: info.Mode().Perm(), ModTime: info.ModTime()}); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("could not journal %s: %w", dir, err))
+		}
+	}
+}
+
+// redoOperation performs again, in their original order, the moves undo
+// reverted, removes the folders undo recreated once they are empty, and
+// journals them as a redo operation
+func redoOperation(ctx context.Context, journalPath string, undo *Operation) (*Result, error) {
+	j, err := openJournal(journalPath, KindRedo, undo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	res := &Result{}
+	defer j.finish(res)
+
+	for i := len(undo.Moves) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		move := undo.Moves[i]
+		if err := checkMove(Move{}, move.Source, move.Dest); err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: move, Err: err})
+			continue
+		}
+		info, err := os.Lstat(move.Source)
+		if err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: move, Err: err})
+			continue
+		}
+		made, err := mkdirAll(filepath.Dir(move.Dest))
+		if err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: move, Err: err})
+			continue
+		}
+		for _, dir := range made {
+			res.Created = append(res.Created, dir)
+			if err := j.folder(dir); err != nil {
+				res.Errors = append(res.Errors, fmt.Errorf("could not write journal: %w", err))
+			}
+		}
+		crossDevice, err := perform(move.Op, move.Source, move.Dest)
+		if err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: move, Err: err})
+			continue
+		}
+		move.Size, move.ModTime = info.Size(), info.ModTime()
+		if info, err := os.Lstat(move.Dest); err == nil {
+			move.Size, move.ModTime = info.Size(), info.ModTime()
+		}
+		res.Moved = append(res.Moved, move)
+		if err := j.move(move, crossDevice); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("could not journal %s: %w", move.Source, err))
+		}
+	}
+
+	removeCreatedFolders(undo.Folders, res, j)
+	return res, nil
+}
+
+// undoLegacy restores the files recorded in a "new|old" LogName written
+// by older versions. The restored entries are removed from the log, and
+// the log itself once every file has been restored.
+func undoLegacy(ctx context.Context, dir string) (*Result, error) {
+	logPath := filepath.Join(dir, LogName)
+
+	// Read the log file
+	file, err := os.Open(logPath)
+	if err != nil {
+		return nil, ErrNoLog
+	}
+	defer file.Close()
+
+	var actions []Move
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Split(line, "|")
+		if len(parts) == 2 {
+			actions = append(actions, Move{Source: parts[0], Dest: parts[1]})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading log file: %w", err)
+	}
+
+	res := &Result{}
+	for _, action := range actions {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if _, err := moveFile(action.Source, action.Dest, false); err != nil {
+			res.Failed = append(res.Failed, &MoveError{Move: action, Err: err})
+		} else {
+			res.Moved = append(res.Moved, action)
+		}
+	}
+
+	// Keep only the entries that could not be restored
+	if len(res.Failed) == 0 {
+		os.Remove(logPath)
+	} else if len(res.Moved) > 0 {
+		var rest strings.Builder
+		for _, failure := range res.Failed {
+			fmt.Fprintf(&rest, "%s|%s\n", failure.Source, failure.Dest)
+		}
+		if err := os.WriteFile(logPath, []byte(rest.String()), 0644); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("could not update log file: %w", err))
+		}
+	}
+	return res, nil
+}
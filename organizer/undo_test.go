@@ -0,0 +1,150 @@
+package organizer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// checkFiles fails unless each of files holds its own name as content
+func checkFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+		} else if string(data) != filepath.Base(file) {
+			t.Errorf("%s holds %q, want %q", file, data, filepath.Base(file))
+		}
+	}
+}
+
+// checkGone fails if any of paths exists
+func checkGone(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if _, err := os.Lstat(filepath.Join(dir, path)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists, want it gone (%v)", path, err)
+		}
+	}
+}
+
+func TestUndoRedoRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.jpg"} {
+		writeFile(t, filepath.Join(dir, name), name)
+	}
+	organized := []string{"gorder_txt/a.txt", "gorder_txt/b.txt", "gorder_jpg/c.jpg"}
+
+	org, err := New(Options{Dir: dir, Target: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := org.Apply(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 3 || len(res.Failed) != 0 {
+		t.Fatalf("organized %d files with failures %v, want 3 and none", len(res.Moved), res.Failed)
+	}
+	checkFiles(t, dir, organized...)
+
+	ops, err := History(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0].Kind != KindOrganize || len(ops[0].Moves) != 3 || len(ops[0].Folders) != 2 {
+		t.Fatalf("journal holds %+v, want one organize with 3 moves and 2 folders", ops)
+	}
+
+	// Undo puts the files back and removes the folders it created
+	if res, err = Undo(ctx, dir, UndoOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 3 || len(res.Failed) != 0 {
+		t.Fatalf("undid %d moves with failures %v, want 3 and none", len(res.Moved), res.Failed)
+	}
+	checkFiles(t, dir, "a.txt", "b.txt", "c.jpg")
+	checkGone(t, dir, "gorder_txt", "gorder_jpg")
+
+	if ops, err = History(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 || !ops[0].Undone() || ops[1].Kind != KindUndo || ops[1].Reverts != ops[0].ID {
+		t.Fatalf("journal holds %+v, want the organize undone by an undo", ops)
+	}
+	if _, err := Undo(ctx, dir, UndoOptions{ID: ops[0].ID}); err == nil {
+		t.Error("undoing the organize twice succeeded")
+	}
+
+	// Redo moves them again, into folders it creates again
+	if res, err = Redo(ctx, dir, UndoOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 3 || len(res.Failed) != 0 {
+		t.Fatalf("redid %d moves with failures %v, want 3 and none", len(res.Moved), res.Failed)
+	}
+	checkFiles(t, dir, organized...)
+	checkGone(t, dir, "a.txt", "b.txt", "c.jpg")
+
+	if ops, err = History(dir); err != nil {
+		t.Fatal(err)
+	}
+	kinds := make([]string, len(ops))
+	for i, op := range ops {
+		kinds[i] = op.Kind
+	}
+	if want := []string{KindOrganize, KindUndo, KindRedo}; !slices.Equal(kinds, want) || !ops[1].Undone() {
+		t.Fatalf("journal holds %v, want %v with the undo redone", kinds, want)
+	}
+	if _, err := Redo(ctx, dir, UndoOptions{}); !errors.Is(err, ErrNoRedo) {
+		t.Errorf("second redo returned %v, want %v", err, ErrNoRedo)
+	}
+
+	// The redo is undone like any other operation
+	if res, err = Undo(ctx, dir, UndoOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 3 || len(res.Failed) != 0 {
+		t.Fatalf("undid %d moves of the redo with failures %v, want 3 and none", len(res.Moved), res.Failed)
+	}
+	checkFiles(t, dir, "a.txt", "b.txt", "c.jpg")
+	checkGone(t, dir, "gorder_txt", "gorder_jpg")
+}
+
+func TestUndoKeepsChangedFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a.txt")
+
+	org, err := New(Options{Dir: dir, Target: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := org.Apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "gorder_txt", "a.txt"), "edited after the move")
+
+	res, err := Undo(ctx, dir, UndoOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 0 || len(res.Failed) != 1 || !errors.Is(res.Failed[0].Err, ErrFileChanged) {
+		t.Fatalf("undo moved %v with failures %v, want nothing moved and the changed file failed", res.Moved, res.Failed)
+	}
+	checkGone(t, dir, "a.txt")
+
+	// Force restores it anyway
+	if res, err = Undo(ctx, dir, UndoOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 1 || len(res.Failed) != 0 {
+		t.Fatalf("forced undo moved %v with failures %v, want the file moved back", res.Moved, res.Failed)
+	}
+	checkGone(t, dir, "gorder_txt")
+}
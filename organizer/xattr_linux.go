@@ -0,0 +1,38 @@
+package organizer
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyXattrs copies the extended attributes of source to dest. Attributes
+// that cannot be read or set, e.g. security ones or on filesystems
+// without xattr support, are skipped.
+func copyXattrs(source, dest string) {
+	size, err := unix.Llistxattr(source, nil)
+	if err != nil || size <= 0 {
+		return
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(source, buf)
+	if err != nil {
+		return
+	}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		n, err := unix.Lgetxattr(source, attr, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		n, err = unix.Lgetxattr(source, attr, value)
+		if err != nil {
+			continue
+		}
+		unix.Lsetxattr(dest, attr, value[:n], 0)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package organizer
+
+// copyXattrs does nothing on platforms without Linux extended attributes
+func copyXattrs(source, dest string) {}